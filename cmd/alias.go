@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// newPeerIdents parses --alias and --group flags.
+func newPeerIdents() (*peerIdents, error) {
+	self := &peerIdents{
+		aliases: make(map[string]string, len(peerAliases)),
+		groups:  make(map[string][]string, len(peerGroups)),
+	}
+
+	for _, s := range peerAliases {
+		name, id, ok := strings.Cut(s, "=")
+		if !ok || name == "" || id == "" {
+			return nil, fmt.Errorf("invalid alias %q, expected NAME=PEER", s)
+		} else if _, ok := self.aliases[name]; ok {
+			return nil, fmt.Errorf("duplicate alias: %s", name)
+		}
+		self.aliases[name] = id
+		self.aliasOrder = append(self.aliasOrder, name)
+	}
+
+	for _, s := range peerGroups {
+		name, ids, ok := strings.Cut(s, "=")
+		if !ok || name == "" || ids == "" {
+			return nil, fmt.Errorf("invalid group %q, expected NAME=PEER[,PEER]...",
+				s)
+		}
+		for id := range strings.SplitSeq(ids, ",") {
+			if id == "" || strings.HasPrefix(id, "@") {
+				return nil, fmt.Errorf("invalid member %q of group %q", id, name)
+			}
+			self.groups[name] = append(self.groups[name], id)
+		}
+	}
+	return self, nil
+}
+
+// peerIdents identifies peers by their name, allowed IP, public key, alias or
+// group.
+type peerIdents struct {
+	aliases    map[string]string
+	aliasOrder []string
+	groups     map[string][]string
+}
+
+// Match returns true if peer matches id. Id can be name of peer, any of its
+// allowed IPs, public key, alias or @group.
+func (self *peerIdents) Match(peer *wg.DumpPeer, id string) bool {
+	if group, ok := strings.CutPrefix(id, "@"); ok {
+		for _, id := range self.groups[group] {
+			if self.matchPeer(peer, id) {
+				return true
+			}
+		}
+		return false
+	}
+	return self.matchPeer(peer, id)
+}
+
+// MatchAny returns true if peer matches any of ids.
+func (self *peerIdents) MatchAny(peer *wg.DumpPeer, ids []string) bool {
+	for _, id := range ids {
+		if self.Match(peer, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *peerIdents) matchPeer(peer *wg.DumpPeer, id string) bool {
+	if target, ok := self.aliases[id]; ok {
+		id = target
+	}
+
+	if peer.Name() == id || peer.PublicKey == id {
+		return true
+	}
+	for _, prefix := range peer.AllowedIPs {
+		if prefix.String() == id || prefix.Addr().String() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the first peer of dump, which matches id, like Match does it,
+// except @group. If no such peer and id (or target of alias) is an IP address
+// or prefix, it returns peer, which routes this address, and matched allowed
+// IP.
+func (self *peerIdents) Find(dump *wg.Dump, id string,
+) (*wg.DumpPeer, netip.Prefix) {
+	for i := range dump.Peers {
+		if p := &dump.Peers[i]; self.matchPeer(p, id) {
+			return p, netip.Prefix{}
+		}
+	}
+
+	if target, ok := self.aliases[id]; ok {
+		id = target
+	}
+
+	addr, err := netip.ParseAddr(id)
+	if err != nil {
+		prefix, err := netip.ParsePrefix(id)
+		if err != nil {
+			return nil, netip.Prefix{}
+		}
+		addr = prefix.Addr()
+	}
+	return dump.Route(addr)
+}
+
+// Alias returns the first alias of peer or empty string if peer has no alias.
+func (self *peerIdents) Alias(peer *wg.DumpPeer) string {
+	for _, name := range self.aliasOrder {
+		if self.matchPeer(peer, self.aliases[name]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// ValidGroup returns error if id is @group and no such group defined.
+func (self *peerIdents) ValidGroup(id string) error {
+	if group, ok := strings.CutPrefix(id, "@"); ok {
+		if _, ok := self.groups[group]; !ok {
+			return fmt.Errorf("unknown group: %s", group)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func usePeerIdents(t *testing.T, aliases, groups []string) *peerIdents {
+	t.Helper()
+	peerAliases, peerGroups = aliases, groups
+	t.Cleanup(func() { peerAliases, peerGroups = nil, nil })
+
+	idents, err := newPeerIdents()
+	require.NoError(t, err)
+	return idents
+}
+
+func TestPeerIdents_Match(t *testing.T) {
+	idents := usePeerIdents(t,
+		[]string{
+			"router=10.0.0.2/32",
+			"laptop=CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+		},
+		[]string{"sites=router,192.168.0.0/16", "roaming=laptop"})
+	dump := newRouteDump()
+	router, laptop := &dump.Peers[0], &dump.Peers[1]
+
+	tests := []struct {
+		id     string
+		router bool
+		laptop bool
+	}{
+		{id: "10.0.0.2/32", router: true},
+		{id: "10.0.0.2", router: true},
+		{id: "192.168.0.0/16", router: true},
+		{id: "192.168.1.0/24", laptop: true},
+		{id: "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=", router: true},
+		{id: "router", router: true},
+		{id: "laptop", laptop: true},
+		{id: "@sites", router: true},
+		{id: "@roaming", laptop: true},
+		{id: "@unknown"},
+		{id: "foobar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.router, idents.Match(router, tt.id))
+			assert.Equal(t, tt.laptop, idents.Match(laptop, tt.id))
+		})
+	}
+
+	assert.True(t, idents.MatchAny(laptop, []string{"router", "@roaming"}))
+	assert.False(t, idents.MatchAny(laptop, []string{"router", "@sites"}))
+	assert.False(t, idents.MatchAny(laptop, nil))
+
+	assert.Equal(t, "router", idents.Alias(router))
+	assert.Equal(t, "laptop", idents.Alias(laptop))
+	dump.Peers[1].PublicKey = "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA="
+	assert.Empty(t, idents.Alias(laptop))
+
+	require.NoError(t, idents.ValidGroup("@sites"))
+	require.NoError(t, idents.ValidGroup("router"))
+	require.ErrorContains(t, idents.ValidGroup("@unknown"),
+		"unknown group: unknown")
+}
+
+func TestNewPeerIdents_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		aliases []string
+		groups  []string
+		wantErr string
+	}{
+		{
+			name:    "alias without peer",
+			aliases: []string{"router"},
+			wantErr: "invalid alias",
+		},
+		{
+			name:    "duplicate alias",
+			aliases: []string{"router=10.0.0.2/32", "router=10.0.0.3/32"},
+			wantErr: "duplicate alias: router",
+		},
+		{
+			name:    "group without peers",
+			groups:  []string{"sites="},
+			wantErr: "invalid group",
+		},
+		{
+			name:    "nested group",
+			groups:  []string{"sites=10.0.0.2/32,@other"},
+			wantErr: `invalid member "@other" of group "sites"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peerAliases, peerGroups = tt.aliases, tt.groups
+			t.Cleanup(func() { peerAliases, peerGroups = nil, nil })
+			_, err := newPeerIdents()
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
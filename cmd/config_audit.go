@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	configAuditFile     string
+	configAuditType     string
+	configAuditSeverity []string
+
+	configAuditCmd = cobra.Command{
+		Use:   "config-audit -c FILE [--config-type TYPE] [--severity FIELD=STATUS]... [wg show wg0 dump]",
+		Short: "check running interface against its configuration file",
+		Long: `It compares running interface with its configuration file and outputs every
+difference, made by wg set and never saved into the file. Dump must be of single
+interface, like wg show wg0 dump or -i wg0.
+
+Configuration file can be of wg-quick(8), like /etc/wireguard/wg0.conf, or of
+systemd.netdev(5), like /etc/systemd/network/wg0.netdev. By default its type is
+detected by .netdev extension. Keys of netdev file, given by PrivateKeyFile= and
+PresharedKeyFile=, are read from their files.
+
+It compares listen port (if configured), fwmark (if not set by wg-quick itself
+for Table=auto and default route), private key (if present in dump), peers,
+their allowed IPs, endpoints (only given by IP address), persistent keepalive
+and preshared keys. Keys are never output.
+
+Every compared field has its own status, which can be changed by --severity as
+FIELD=ok, FIELD=warning or FIELD=critical. Differences of fields with ok status
+are ignored. Fields and their default status:
+
+` + configAuditFieldsHelp(),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("config audit", args,
+				configAuditResponse))
+		},
+	}
+)
+
+// configAuditSeverities are default statuses of drifted fields.
+var configAuditSeverities = map[wg.DriftField]int{
+	wg.DriftListenPort:   monitoringplugin.CRITICAL,
+	wg.DriftFwMark:       monitoringplugin.WARNING,
+	wg.DriftPrivateKey:   monitoringplugin.CRITICAL,
+	wg.DriftMissingPeer:  monitoringplugin.CRITICAL,
+	wg.DriftAddedPeer:    monitoringplugin.WARNING,
+	wg.DriftAllowedIPs:   monitoringplugin.CRITICAL,
+	wg.DriftEndpoint:     monitoringplugin.WARNING,
+	wg.DriftKeepalive:    monitoringplugin.WARNING,
+	wg.DriftPresharedKey: monitoringplugin.CRITICAL,
+}
+
+func init() {
+	f := configAuditCmd.Flags()
+	f.StringVarP(&configAuditFile, "config", "c", "",
+		"configuration file of wg-quick(8) or systemd.netdev(5)")
+	f.StringVar(&configAuditType, "config-type", "auto",
+		"type of configuration file: "+strings.Join(configTypes, ", "))
+	f.StringArrayVar(&configAuditSeverity, "severity", nil,
+		"status of drifted field as FIELD=ok|warning|critical")
+	_ = configAuditCmd.MarkFlagRequired("config")
+}
+
+func configAuditFieldsHelp() string {
+	var b strings.Builder
+	for _, field := range wg.DriftFields {
+		fmt.Fprintf(&b, "  %-22s %s\n", field, strings.ToLower(
+			monitoringplugin.StatusCode2Text(configAuditSeverities[field])))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func configAuditResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	if err := singleInterface(dump); err != nil {
+		return err
+	}
+
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	severities, err := newConfigAuditSeverities()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readConfig(configAuditFile, configAuditType)
+	if err != nil {
+		return err
+	}
+	resp.WithDefaultOkMessage("running interface matches " + configAuditFile)
+
+	var drifts int
+	for _, d := range cfg.Diff(dump) {
+		status := severities[d.Field]
+		if status == monitoringplugin.OK {
+			continue
+		}
+		drifts++
+		resp.UpdateStatus(status, driftString(&d, idents))
+	}
+
+	point := monitoringplugin.NewPerformanceDataPoint("drifts", drifts)
+	if err := resp.AddPerformanceDataPoint(point); err != nil {
+		return fmt.Errorf("add performance point drifts=%v: %w", drifts, err)
+	}
+	return nil
+}
+
+// singleInterface returns error if dump is of multiple interfaces, like wg show
+// all dump without -i.
+func singleInterface(dump *wg.Dump) error {
+	if dump.Multiple {
+		return errors.New(
+			"peers of multiple interfaces in dump, select single interface by -i")
+	}
+	return nil
+}
+
+// newConfigAuditSeverities returns default statuses of fields, changed by
+// --severity.
+func newConfigAuditSeverities() (map[wg.DriftField]int, error) {
+	severities := make(map[wg.DriftField]int, len(configAuditSeverities))
+	for field, status := range configAuditSeverities {
+		severities[field] = status
+	}
+
+	for _, s := range configAuditSeverity {
+		name, value, _ := strings.Cut(s, "=")
+		field := wg.DriftField(name)
+		if _, ok := severities[field]; !ok {
+			return nil, fmt.Errorf("severity %q: unknown field %q", s, name)
+		}
+
+		status, ok := parseStatus(value)
+		if !ok {
+			return nil, fmt.Errorf(
+				"invalid severity %q, expected FIELD=ok|warning|critical", s)
+		}
+		severities[field] = status
+	}
+	return severities, nil
+}
+
+// parseStatus parses name of status: ok, warning or critical.
+func parseStatus(s string) (int, bool) {
+	switch strings.ToLower(s) {
+	case "ok":
+		return monitoringplugin.OK, true
+	case "warning":
+		return monitoringplugin.WARNING, true
+	case "critical":
+		return monitoringplugin.CRITICAL, true
+	}
+	return 0, false
+}
+
+var configTypes = []string{"auto", "wg-quick", "netdev"}
+
+// readConfig parses configuration file of given type. Type auto means netdev
+// for files with .netdev extension and wg-quick for anything else.
+func readConfig(name, typ string) (*wg.Config, error) {
+	parse := wg.NewQuickConfig
+	switch typ {
+	case "auto":
+		if filepath.Ext(name) == ".netdev" {
+			parse = wg.NewNetdevConfig
+		}
+	case "wg-quick":
+	case "netdev":
+		parse = wg.NewNetdevConfig
+	default:
+		return nil, fmt.Errorf("unknown config type %q, expected one of: %s", typ,
+			strings.Join(configTypes, ", "))
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open config: %w", err)
+	}
+	defer f.Close()
+
+	cfg, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %q: %w", name, err)
+	}
+	return cfg, nil
+}
+
+// driftString returns drift as single line, like
+//
+//	allowed-ips of laptop (KEY): config 10.0.0.2/32, running (none)
+func driftString(d *wg.Drift, idents *peerIdents) string {
+	var b strings.Builder
+	b.WriteString(string(d.Field))
+	if d.Peer != "" {
+		b.WriteString(" of ")
+		if alias := idents.Alias(&wg.DumpPeer{PublicKey: d.Peer}); alias != "" {
+			b.WriteString(alias + " (" + wg.KeyFingerprint(d.Peer) + ")")
+		} else {
+			b.WriteString(d.Peer)
+		}
+	}
+	b.WriteString(": config " + d.Config + ", running " + d.Running)
+	return b.String()
+}
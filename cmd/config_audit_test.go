@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useConfigAudit(t *testing.T, file string, severity []string) {
+	t.Helper()
+	configAuditFile, configAuditType, configAuditSeverity = file, "auto", severity
+	t.Cleanup(func() {
+		configAuditFile, configAuditType, configAuditSeverity = "", "auto", nil
+	})
+}
+
+func TestConfigAuditResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useConfigAudit(t, "../wg/testdata/wg0.conf", nil)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, configAuditResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: running interface matches ../wg/testdata/wg0.conf | 'drifts'=0",
+		resp.GetInfo().RawOutput)
+}
+
+func TestConfigAuditResponse_drift(t *testing.T) {
+	usePeerIdents(t,
+		[]string{"laptop=BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="}, nil)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.ListenPort = 51820
+	dump.Peers[0].AllowedIPs = append(dump.Peers[0].AllowedIPs,
+		netip.MustParsePrefix("192.168.0.0/16"))
+	dump.Peers[1].Keepalive = 25 * time.Second
+	useConfigAudit(t, "../wg/testdata/wg0.conf", nil)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, configAuditResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "listen-port: config 12345, running 51820")
+	assert.Contains(t, output,
+		"allowed-ips of laptop (BBBBBBBB): config 10.0.0.2/32, running 10.0.0.2/32, 192.168.0.0/16")
+	assert.Contains(t, output,
+		"persistent-keepalive of CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=: config off, running 25s")
+	assert.Contains(t, output, "'drifts'=3")
+
+	resp = monitoringplugin.NewResponse("test OK")
+	useConfigAudit(t, "../wg/testdata/wg0.conf", []string{
+		"listen-port=ok", "allowed-ips=warning",
+		"persistent-keepalive=Critical",
+	})
+	require.NoError(t, configAuditResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.NotContains(t, output, "listen-port")
+	assert.Contains(t, output, "'drifts'=2")
+}
+
+func TestConfigAuditResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+
+	tests := []struct {
+		name     string
+		file     string
+		severity []string
+		err      string
+	}{
+		{
+			name: "not exists",
+			file: filepath.Join(t.TempDir(), "wg0.conf"),
+			err:  "open config",
+		},
+		{
+			name:     "unknown field",
+			file:     "../wg/testdata/wg0.conf",
+			severity: []string{"foo=ok"},
+			err:      "unknown field \"foo\"",
+		},
+		{
+			name:     "invalid status",
+			file:     "../wg/testdata/wg0.conf",
+			severity: []string{"endpoint=unknown"},
+			err:      "invalid severity \"endpoint=unknown\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfigAudit(t, tt.file, tt.severity)
+			err := configAuditResponse(dump, monitoringplugin.NewResponse("test OK"))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	name := filepath.Join(t.TempDir(), "wg0.conf")
+	require.NoError(t, os.WriteFile(name, []byte("[Peer]\nPublicKey = BBBB\n"), 0o600))
+	useConfigAudit(t, name, nil)
+	err := configAuditResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "no [Interface] section")
+}
+
+func TestReadConfig(t *testing.T) {
+	quick, err := readConfig("../wg/testdata/wg0.conf", "auto")
+	require.NoError(t, err)
+	netdev, err := readConfig("../wg/testdata/wg0.netdev", "auto")
+	require.NoError(t, err)
+	assert.Equal(t, quick, netdev)
+
+	_, err = readConfig("../wg/testdata/wg0.netdev", "wg-quick")
+	require.ErrorContains(t, err, "unknown section [NetDev]")
+
+	_, err = readConfig("../wg/testdata/wg0.conf", "netdev")
+	require.ErrorContains(t, err, "no [WireGuard] section")
+
+	_, err = readConfig("../wg/testdata/wg0.conf", "foo")
+	require.ErrorContains(t, err, "unknown config type \"foo\"")
+}
+
+func TestConfigAuditResponse_singleInterface(t *testing.T) {
+	t.Cleanup(func() { allInterfaces, interfaces = false, nil })
+	useConfigAudit(t, "../wg/testdata/wg0.conf", nil)
+	allDump := []string{"../wg/testdata/wg_show_all_dump.txt"}
+
+	allInterfaces = true
+	dump, err := fileDump(allDump)
+	require.NoError(t, err)
+	err = configAuditResponse(&dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "peers of multiple interfaces")
+
+	allInterfaces, interfaces = false, []string{"wg0"}
+	dump, err = fileDump(allDump)
+	require.NoError(t, err)
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, configAuditResponse(&dump, resp))
+	assert.NotContains(t, resp.GetInfo().RawOutput, "wg1")
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// countThreshold is a threshold given as number of peers, like 10, or as
+// percentage of all peers, like 10%. It implements pflag.Value.
+type countThreshold struct {
+	Value   float64
+	Percent bool
+	set     bool
+}
+
+func (self *countThreshold) Set(s string) error {
+	num, percent := strings.CutSuffix(s, "%")
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return fmt.Errorf("parse threshold %q: %w", s, err)
+	} else if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return fmt.Errorf("invalid threshold %q", s)
+	} else if !percent && v != math.Trunc(v) {
+		return fmt.Errorf("threshold %q must be integer or percentage", s)
+	}
+	*self = countThreshold{Value: v, Percent: percent, set: true}
+	return nil
+}
+
+func (self *countThreshold) String() string {
+	if !self.set {
+		return ""
+	}
+	s := strconv.FormatFloat(self.Value, 'f', -1, 64)
+	if self.Percent {
+		s += "%"
+	}
+	return s
+}
+
+func (self *countThreshold) Type() string { return "N[%]" }
+
+// IsSet returns true if threshold was given.
+func (self *countThreshold) IsSet() bool { return self.set }
+
+// Count returns threshold as number of peers. Percentage is converted into
+// number of peers from total, rounded down.
+func (self *countThreshold) Count(total int) int {
+	if self.Percent {
+		return int(math.Floor(float64(total) * self.Value / 100))
+	}
+	return int(self.Value)
+}
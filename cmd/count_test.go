@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountThreshold(t *testing.T) {
+	var th countThreshold
+	assert.False(t, th.IsSet())
+	assert.Empty(t, th.String())
+	assert.Equal(t, "N[%]", th.Type())
+
+	require.NoError(t, th.Set("3"))
+	assert.True(t, th.IsSet())
+	assert.Equal(t, "3", th.String())
+	assert.Equal(t, 3, th.Count(100))
+
+	require.NoError(t, th.Set("12.5%"))
+	assert.Equal(t, "12.5%", th.String())
+	assert.Equal(t, 12, th.Count(100))
+	assert.Equal(t, 1, th.Count(15))
+	assert.Equal(t, 0, th.Count(7))
+}
+
+func TestCountThreshold_errors(t *testing.T) {
+	tests := []struct {
+		s       string
+		wantErr string
+	}{
+		{s: "", wantErr: "parse threshold"},
+		{s: "%", wantErr: "parse threshold"},
+		{s: "foo", wantErr: "parse threshold"},
+		{s: "-1", wantErr: "invalid threshold"},
+		{s: "1.5", wantErr: "must be integer or percentage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			var th countThreshold
+			require.ErrorContains(t, th.Set(tt.s), tt.wantErr)
+			assert.False(t, th.IsSet())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var dumpCmd = cobra.Command{
+	Use:   "dump [wg show wg0 dump]",
+	Short: "print parsed dump as JSON",
+	Long: `It reads wireguard dump, like any other command, and prints it as JSON for
+scripting. Private and preshared keys are always replaced by "(hidden)".`,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printDump(args)
+	},
+}
+
+func printDump(args []string) error {
+	dump, err := NewWgDump(args)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dump); err != nil {
+		return fmt.Errorf("encode dump: %w", err)
+	}
+	return nil
+}
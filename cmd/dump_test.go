@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestPrintDump(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.PrivateKey = "ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZA="
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	stdout := os.Stdout
+	t.Cleanup(func() { os.Stdout = stdout })
+	os.Stdout = f
+
+	require.NoError(t, printDump(nil))
+	b, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	t.Log(string(b))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, "(hidden)", got["private_key"])
+	assert.Equal(t, dump.PublicKey, got["public_key"])
+	assert.Len(t, got["peers"], len(dump.Peers))
+	assert.NotContains(t, string(b), dump.PrivateKey)
+}
+
+func TestPrintDump_error(t *testing.T) {
+	wantErr := errors.New("test error")
+	useSource(t, SourceFunc(func(args []string) (wg.Dump, error) {
+		return wg.Dump{}, wantErr
+	}))
+	require.ErrorIs(t, printDump(nil), wantErr)
+}
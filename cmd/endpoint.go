@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	endpointState        string
+	endpointChangeStatus string
+	endpointWindow       time.Duration
+	endpointRoamsWarn    int
+	endpointRoamsCrit    int
+	endpointExpect       []string
+	endpointExclude      []string
+
+	endpointCmd = cobra.Command{
+		Use:   "endpoint --state FILE [--window 1h] [--roams-warn N] [--roams-crit N] [-e [PEER=]CIDR]... [-x peer]... [wg show wg0 dump]",
+		Short: "check endpoint changes and roaming of peers",
+		Long: `It saves endpoints of peers into given state file and outputs every peer,
+which endpoint changed since previous run, with status given by
+--change-status. For site-to-site links an unexpected change can mean ISP
+failover or hijacking.
+
+It counts endpoint changes of every peer within --window and outputs warning or
+critical status, if a peer roamed more times of --roams-warn or --roams-crit,
+which means its endpoint is flapping.
+
+With -e it outputs critical status for every peer, which endpoint is outside of
+expected networks. Networks can be given for all peers as CIDR or for some peers
+or @groups as PEER=CIDR, which have precedence over networks of all peers.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("endpoints", args, endpointResponse))
+		},
+	}
+)
+
+func init() {
+	f := endpointCmd.Flags()
+	f.StringVar(&endpointState, "state", "",
+		"file with endpoints of previous runs")
+	f.StringVar(&endpointChangeStatus, "change-status", "warning",
+		"status of changed endpoint: ok, warning or critical")
+	f.DurationVar(&endpointWindow, "window", time.Hour,
+		"count endpoint changes within this window")
+	f.IntVar(&endpointRoamsWarn, "roams-warn", 0,
+		"warning if peer roamed more times within window")
+	f.IntVar(&endpointRoamsCrit, "roams-crit", 0,
+		"critical if peer roamed more times within window")
+	f.StringArrayVarP(&endpointExpect, "expect", "e", nil,
+		"expected network of endpoints as CIDR or PEER=CIDR")
+	f.StringArrayVarP(&endpointExclude, "exclude", "x", nil,
+		"peers or @groups to exclude from check")
+}
+
+// endpointCounts is result of checking endpoints of peers.
+type endpointCounts struct {
+	Changed    int
+	Roams      int
+	Unexpected int
+}
+
+func endpointResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	if endpointState == "" {
+		return errors.New("--state is required")
+	}
+
+	changeStatus, ok := parseStatus(endpointChangeStatus)
+	if !ok {
+		return fmt.Errorf("invalid --change-status %q, expected ok, warning or critical",
+			endpointChangeStatus)
+	}
+
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	networks, err := newEndpointNetworks(idents)
+	if err != nil {
+		return err
+	}
+
+	var counts endpointCounts
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		if idents.MatchAny(peer, endpointExclude) || networks.Contains(peer) {
+			continue
+		}
+		counts.Unexpected++
+		if err := outputPeerDetails(peer, monitoringplugin.CRITICAL,
+			"endpoint outside of expected networks", resp); err != nil {
+			return err
+		}
+	}
+
+	hasPrev, err := updatePeersState(endpointState, "endpoint", dump,
+		func(peer *wg.DumpPeer, prev, cur *peerState) (bool, error) {
+			roams, changed := endpointRoams(prev, cur)
+			if idents.MatchAny(peer, endpointExclude) {
+				return false, nil
+			}
+			counts.Roams += len(roams)
+
+			if changed {
+				counts.Changed++
+				err := outputPeerDetails(peer, changeStatus,
+					"previous endpoint: "+prev.Endpoint.String(), resp)
+				if err != nil {
+					return true, err
+				}
+			}
+
+			if status := roamsStatus(len(roams)); status != monitoringplugin.OK {
+				return true, outputPeerDetails(peer, status, fmt.Sprintf(
+					"roamed %d times in %s", len(roams), endpointWindow), resp)
+			}
+			return true, nil
+		})
+
+	if err != nil {
+		return err
+	} else if !hasPrev {
+		resp.WithDefaultOkMessage(
+			"no previous state, endpoint changes will be checked by next run")
+	} else {
+		resp.WithDefaultOkMessage("no endpoint changes")
+	}
+	return counts.PerfData(resp)
+}
+
+// endpointRoams returns endpoint changes of peer within --window, including
+// change since previous run, and saves them into current state. It returns
+// true, if endpoint changed since previous run.
+func endpointRoams(prev, cur *peerState) ([]time.Time, bool) {
+	since := cur.Time.Add(-endpointWindow)
+	var roams []time.Time
+	for _, t := range prev.Roams {
+		if t.After(since) {
+			roams = append(roams, t)
+		}
+	}
+
+	cur.Roams = roams
+	// Endpoint of peer is unknown, until it connects first time, like after
+	// restart of interface. Keep the last known one for detecting next change.
+	if !cur.Endpoint.IsValid() {
+		cur.Endpoint = prev.Endpoint
+		return roams, false
+	}
+
+	changed := prev.Endpoint.IsValid() && prev.Endpoint != cur.Endpoint
+	if changed {
+		roams = append(roams, cur.Time)
+		cur.Roams = roams
+	}
+	return roams, changed
+}
+
+func roamsStatus(roams int) int {
+	switch {
+	case endpointRoamsCrit != 0 && roams > endpointRoamsCrit:
+		return monitoringplugin.CRITICAL
+	case endpointRoamsWarn != 0 && roams > endpointRoamsWarn:
+		return monitoringplugin.WARNING
+	}
+	return monitoringplugin.OK
+}
+
+func (self *endpointCounts) PerfData(resp *monitoringplugin.Response) error {
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		monitoringplugin.NewPerformanceDataPoint("changed", self.Changed),
+		monitoringplugin.NewPerformanceDataPoint("roams", self.Roams),
+		monitoringplugin.NewPerformanceDataPoint("unexpected", self.Unexpected),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
+
+// newEndpointNetworks parses --expect flags.
+func newEndpointNetworks(idents *peerIdents) (*endpointNetworks, error) {
+	self := &endpointNetworks{idents: idents}
+	for _, s := range endpointExpect {
+		// public key can end with '=', so cut by the last one.
+		id, cidr := "", s
+		if i := strings.LastIndexByte(s, '='); i >= 0 {
+			id, cidr = s[:i], s[i+1:]
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("expected network %q: %w", s, err)
+		} else if err := idents.ValidGroup(id); err != nil {
+			return nil, fmt.Errorf("expected network %q: %w", s, err)
+		}
+		self.networks = append(self.networks,
+			endpointNetwork{ID: id, Prefix: prefix.Masked()})
+	}
+	return self, nil
+}
+
+// endpointNetwork is expected network of endpoints of peer or @group, or of all
+// peers, if ID is empty.
+type endpointNetwork struct {
+	ID     string
+	Prefix netip.Prefix
+}
+
+type endpointNetworks struct {
+	idents   *peerIdents
+	networks []endpointNetwork
+}
+
+// Contains returns true if endpoint of peer is within its expected networks,
+// or no networks expected, or peer has no endpoint.
+func (self *endpointNetworks) Contains(peer *wg.DumpPeer) bool {
+	if !peer.HasEndpoint() {
+		return true
+	}
+
+	var global, own []netip.Prefix
+	for _, n := range self.networks {
+		switch {
+		case n.ID == "":
+			global = append(global, n.Prefix)
+		case self.idents.Match(peer, n.ID):
+			own = append(own, n.Prefix)
+		}
+	}
+
+	prefixes := own
+	if len(prefixes) == 0 {
+		prefixes = global
+	}
+
+	if len(prefixes) == 0 {
+		return true
+	}
+	addr := peer.Endpoint.Addr().Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
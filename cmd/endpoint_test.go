@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func useEndpointState(t *testing.T) string {
+	t.Helper()
+	endpointState = filepath.Join(t.TempDir(), "endpoint.json")
+	t.Cleanup(func() {
+		endpointState, endpointChangeStatus = "", "warning"
+		endpointWindow = time.Hour
+		endpointRoamsWarn, endpointRoamsCrit = 0, 0
+		endpointExpect, endpointExclude = nil, nil
+	})
+	return endpointState
+}
+
+func TestEndpointResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useEndpointState(t)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no previous state, endpoint changes will be checked by next run | 'changed'=0 'roams'=0 'unexpected'=0",
+		resp.GetInfo().RawOutput)
+
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no endpoint changes | 'changed'=0 'roams'=0 'unexpected'=0",
+		resp.GetInfo().RawOutput)
+}
+
+func TestEndpointResponse_changed(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useEndpointState(t)
+	require.NoError(t, endpointResponse(dump,
+		monitoringplugin.NewResponse("test OK")))
+
+	dump.Peers[0].Endpoint = netip.MustParseAddrPort("127.0.0.1:54321")
+	dump.Peers[1].Endpoint = netip.AddrPort{}
+	dump.Peers[2].Endpoint = netip.MustParseAddrPort("127.0.0.1:54323")
+	endpointExclude = []string{"10.0.0.4/32"}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"peer: 10.0.0.2/32, previous endpoint: 10.0.0.1:54321, endpoint: 127.0.0.1:54321")
+	assert.NotContains(t, output, "10.0.0.3/32")
+	assert.NotContains(t, output, "10.0.0.4/32")
+	assert.Contains(t, output, "'changed'=1 'roams'=1 'unexpected'=0")
+
+	state, err := loadState(endpointState)
+	require.NoError(t, err)
+	for i, roams := range [...]int{1, 0, 1, 0} {
+		st := state.Peer(&dump.Peers[i])
+		require.NotNil(t, st)
+		assert.Len(t, st.Roams, roams, dump.Peers[i].Name())
+	}
+}
+
+func TestEndpointResponse_unknown(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useEndpointState(t)
+	peer := &dump.Peers[0]
+	require.NoError(t, endpointResponse(dump,
+		monitoringplugin.NewResponse("test OK")))
+
+	peer.Endpoint = netip.AddrPort{}
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+
+	state, err := loadState(endpointState)
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddrPort("10.0.0.1:54321"),
+		state.Peer(peer).Endpoint)
+
+	peer.Endpoint = netip.MustParseAddrPort("127.0.0.1:54321")
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"peer: 10.0.0.2/32, previous endpoint: 10.0.0.1:54321, endpoint: 127.0.0.1:54321")
+	assert.Contains(t, output, "'changed'=1 'roams'=1 'unexpected'=0")
+}
+
+func TestEndpointResponse_roams(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useEndpointState(t)
+	endpointChangeStatus = "ok"
+	endpointRoamsWarn, endpointRoamsCrit = 1, 2
+
+	state, err := loadState(endpointState)
+	require.NoError(t, err)
+	now := time.Now()
+	peer := &dump.Peers[0]
+	st := newPeerState(peer, now.Add(-time.Minute))
+	st.Roams = []time.Time{now.Add(-2 * time.Hour), now.Add(-30 * time.Minute)}
+	state.SetPeer(peer, st)
+	st = newPeerState(&dump.Peers[1], now.Add(-time.Minute))
+	st.Roams = []time.Time{now.Add(-20 * time.Minute), now.Add(-10 * time.Minute)}
+	state.SetPeer(&dump.Peers[1], st)
+	require.NoError(t, state.Save(endpointState))
+
+	peer.Endpoint = netip.MustParseAddrPort("127.0.0.1:54321")
+	dump.Peers[1].Endpoint = netip.MustParseAddrPort("127.0.0.1:54322")
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "peer: 10.0.0.2/32, roamed 2 times in 1h0m0s")
+	assert.Contains(t, output, "peer: 10.0.0.3/32, roamed 3 times in 1h0m0s")
+	assert.Contains(t, output, "'changed'=2 'roams'=5 'unexpected'=0")
+}
+
+func TestEndpointNetworks_Contains(t *testing.T) {
+	usePeerIdents(t, nil, []string{"sites=10.0.0.3/32,10.0.0.4/32"})
+	idents, err := newPeerIdents()
+	require.NoError(t, err)
+	t.Cleanup(func() { endpointExpect = nil })
+	endpointExpect = []string{
+		"192.0.2.0/24",
+		"@sites=198.51.100.0/24",
+		"10.0.0.4/32=203.0.113.1/32",
+	}
+	networks, err := newEndpointNetworks(idents)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		contains bool
+	}{
+		{name: "10.0.0.2/32", endpoint: "192.0.2.1:51820", contains: true},
+		{name: "10.0.0.2/32", endpoint: "198.51.100.1:51820"},
+		{name: "10.0.0.2/32", contains: true},
+		{name: "10.0.0.2/32", endpoint: "[::ffff:192.0.2.1]:51820", contains: true},
+		{name: "10.0.0.3/32", endpoint: "198.51.100.1:51820", contains: true},
+		{name: "10.0.0.3/32", endpoint: "192.0.2.1:51820"},
+		{name: "10.0.0.4/32", endpoint: "203.0.113.1:51820", contains: true},
+		{name: "10.0.0.4/32", endpoint: "198.51.100.1:51820", contains: true},
+		{name: "10.0.0.4/32", endpoint: "203.0.113.2:51820"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.endpoint, func(t *testing.T) {
+			peer := &wg.DumpPeer{
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix(tt.name)},
+			}
+			if tt.endpoint != "" {
+				peer.Endpoint = netip.MustParseAddrPort(tt.endpoint)
+			}
+			assert.Equal(t, tt.contains, networks.Contains(peer))
+		})
+	}
+}
+
+func TestEndpointResponse_unexpected(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useEndpointState(t)
+	endpointExpect = []string{"10.0.0.0/24", "10.0.0.5/32=192.168.0.0/16"}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, endpointResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"peer: 10.0.0.5/32, endpoint outside of expected networks, endpoint: 10.0.0.1:54324")
+	assert.Contains(t, output, "'unexpected'=1")
+}
+
+func TestEndpointResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	err := endpointResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "--state is required")
+
+	useEndpointState(t)
+	endpointChangeStatus = "foo"
+	err = endpointResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "invalid --change-status \"foo\"")
+
+	endpointChangeStatus = "warning"
+	endpointExpect = []string{"10.0.0.0/33"}
+	err = endpointResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "expected network \"10.0.0.0/33\"")
+
+	endpointExpect = []string{"@foo=10.0.0.0/24"}
+	err = endpointResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "expected network \"@foo=10.0.0.0/24\"")
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+)
+
+var (
+	outputFormat string
+
+	outputFormats = []string{"text", "json"}
+
+	rePerfDataPoint = regexp.MustCompile(`'([^']*)'=(\S*)`)
+	rePerfDataValue = regexp.MustCompile(`^([-+0-9.eE]*)(.*)$`)
+)
+
+func checkOutputFormat() error {
+	for _, s := range outputFormats {
+		if s == outputFormat {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format: %s", outputFormat)
+}
+
+// outputAndExit outputs resp in format given by --format and exits with its
+// status code.
+func outputAndExit(resp *monitoringplugin.Response) {
+	if outputFormat != "json" {
+		resp.OutputAndExit()
+	}
+
+	b, err := responseJSON(resp)
+	if err != nil {
+		resp.UpdateStatus(monitoringplugin.UNKNOWN, err.Error())
+		resp.OutputAndExit()
+	}
+	fmt.Println(string(b))
+	os.Exit(resp.GetStatusCode())
+}
+
+// jsonResponse is result of a check in JSON format.
+type jsonResponse struct {
+	Status     string         `json:"status"`
+	StatusCode int            `json:"status_code"`
+	Output     string         `json:"output"`
+	Messages   []jsonMessage  `json:"messages"`
+	PerfData   []jsonPerfData `json:"perfdata"`
+}
+
+type jsonMessage struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// jsonPerfData is a performance data point. Thresholds are ranges in Nagios
+// format, like 10:20 or ~:10.
+type jsonPerfData struct {
+	Metric string      `json:"metric"`
+	Label  string      `json:"label,omitempty"`
+	Value  json.Number `json:"value"`
+	Unit   string      `json:"unit,omitempty"`
+	Warn   string      `json:"warn,omitempty"`
+	Crit   string      `json:"crit,omitempty"`
+	Min    string      `json:"min,omitempty"`
+	Max    string      `json:"max,omitempty"`
+}
+
+func responseJSON(resp *monitoringplugin.Response) ([]byte, error) {
+	resp.SetPerformanceDataJSONLabel(true)
+	info := resp.GetInfo()
+	output, perfData, _ := strings.Cut(info.RawOutput, " | ")
+
+	r := jsonResponse{
+		Status:     monitoringplugin.StatusCode2Text(info.StatusCode),
+		StatusCode: info.StatusCode,
+		Output:     output,
+		Messages:   make([]jsonMessage, len(info.Messages)),
+		PerfData:   []jsonPerfData{},
+	}
+
+	for i, m := range info.Messages {
+		r.Messages[i] = jsonMessage{
+			Status:  monitoringplugin.StatusCode2Text(m.Status),
+			Message: m.Message,
+		}
+	}
+
+	for _, m := range rePerfDataPoint.FindAllStringSubmatch(perfData, -1) {
+		point, err := parsePerfData(m[1], m[2])
+		if err != nil {
+			return nil, err
+		}
+		r.PerfData = append(r.PerfData, point)
+	}
+
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	return b, nil
+}
+
+// parsePerfData parses performance data point with JSON label, like
+// '{"metric":"rx"}'=10b;20;30;0;100.
+func parsePerfData(label, value string) (jsonPerfData, error) {
+	var point jsonPerfData
+	if err := json.Unmarshal([]byte(label), &point); err != nil {
+		return point, fmt.Errorf("parse label of performance data %q: %w",
+			label, err)
+	}
+
+	fields := strings.Split(value, ";")
+	fields = append(fields, make([]string, max(0, 5-len(fields)))...)
+	m := rePerfDataValue.FindStringSubmatch(fields[0])
+	point.Value, point.Unit = json.Number(m[1]), m[2]
+	point.Warn, point.Crit = fields[1], fields[2]
+	point.Min, point.Max = fields[3], fields[4]
+	return point, nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseJSON(t *testing.T) {
+	resp := monitoringplugin.NewResponse("test OK")
+	point := monitoringplugin.NewPerformanceDataPoint("latest handshake", 70).
+		SetUnit("s").SetLabel("10.0.0.2/32")
+	point.NewThresholds(0, 60, 0, 120)
+	require.NoError(t, resp.AddPerformanceDataPoint(point))
+	require.NoError(t, resp.AddPerformanceDataPoint(
+		monitoringplugin.NewPerformanceDataPoint("rx", uint64(1234)).
+			SetUnit("b").SetMax(2000)))
+	resp.UpdateStatus(resp.GetStatusCode(), "peer: 10.0.0.2/32")
+
+	b, err := responseJSON(resp)
+	require.NoError(t, err)
+	t.Log(string(b))
+
+	var got jsonResponse
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, jsonResponse{
+		Status:     "WARNING",
+		StatusCode: monitoringplugin.WARNING,
+		Output: "WARNING: latest handshake (10.0.0.2/32) is outside of WARNING threshold\n" +
+			"peer: 10.0.0.2/32",
+		Messages: []jsonMessage{
+			{
+				Status:  "WARNING",
+				Message: "latest handshake (10.0.0.2/32) is outside of WARNING threshold",
+			},
+			{Status: "WARNING", Message: "peer: 10.0.0.2/32"},
+		},
+		PerfData: []jsonPerfData{
+			{
+				Metric: "latest handshake",
+				Label:  "10.0.0.2/32",
+				Value:  "70",
+				Unit:   "s",
+				Warn:   "60",
+				Crit:   "120",
+			},
+			{Metric: "rx", Value: "1234", Unit: "b", Max: "2000"},
+		},
+	}, got)
+}
+
+func TestResponseJSON_empty(t *testing.T) {
+	b, err := responseJSON(monitoringplugin.NewResponse("test OK"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "status": "OK",
+  "status_code": 0,
+  "output": "OK: test OK",
+  "messages": [],
+  "perfdata": []
+}`, string(b))
+}
+
+func TestParsePerfData(t *testing.T) {
+	point, err := parsePerfData(`{"metric":"conflicts"}`, "1;;0")
+	require.NoError(t, err)
+	assert.Equal(t, jsonPerfData{
+		Metric: "conflicts",
+		Value:  "1",
+		Crit:   "0",
+	}, point)
+
+	point, err = parsePerfData(`{"metric":"rx rate"}`, "-1.5e+09")
+	require.NoError(t, err)
+	assert.Equal(t, jsonPerfData{Metric: "rx rate", Value: "-1.5e+09"}, point)
+
+	_, err = parsePerfData("conflicts", "1")
+	require.ErrorContains(t, err, "parse label of performance data")
+}
+
+func TestCheckOutputFormat(t *testing.T) {
+	t.Cleanup(func() { outputFormat = "text" })
+	for _, outputFormat = range outputFormats {
+		require.NoError(t, checkOutputFormat())
+	}
+	outputFormat = "xml"
+	require.ErrorContains(t, checkOutputFormat(), "unknown output format: xml")
+}
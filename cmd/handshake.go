@@ -3,6 +3,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/dsh2dsh/go-monitoringplugin/v2"
@@ -13,20 +15,36 @@ import (
 
 var (
 	handshakeExclude             []string
+	handshakePeer                string
+	handshakeEach                bool
 	handshakeWarn, handshakeCrit time.Duration
+	handshakeThresholdFlags      []string
 
 	handshakeCmd = cobra.Command{
-		Use:   "handshake [-w 5m] [-c 15m] [-x peer]... [wg show wg0 dump]",
+		Use:   "handshake [-w 5m] [-c 15m] [-t peer=5m:15m]... [-x peer]... [-p peer] [--each] [wg show wg0 dump]",
 		Short: "check oldest latest handshake",
 		Long: `It executes given wg(8) command and reads its output or stdin, if no
 command was given at all.
 
-It analizes latest handshake of every peer and outputs warning or critical
-status if any of them is greater of given threshold.`,
+It analizes latest handshake of every peer against its own thresholds and
+outputs the worst of them. If multiple peers have the same status, it outputs
+the one with the oldest latest handshake.
+
+With -p it checks given peer only. Peer can be given by its alias, name, public
+key or by any IP address, routed by the peer.
+
+With --each it checks every peer against its thresholds, instead of the worst
+one only, and outputs single line of every peer outside of thresholds and
+performance data of every peer.
+
+Thresholds of some peers or groups of peers can be changed by -t. Peer can be
+given by name, allowed IP, public key, alias or @group. Thresholds of peer
+itself have precedence over thresholds of its groups, which have precedence
+over -w and -c. If peer belongs to multiple groups, the first given -t wins.`,
 
 		Run: func(cmd *cobra.Command, args []string) {
-			monitoringResponse("latest handshake", args, handshakeResponse).
-				OutputAndExit()
+			outputAndExit(monitoringResponse("latest handshake", args,
+				handshakeResponse))
 		},
 	}
 )
@@ -34,17 +52,40 @@ status if any of them is greater of given threshold.`,
 func init() {
 	f := handshakeCmd.Flags()
 	f.StringArrayVarP(&handshakeExclude, "exclude", "x", nil,
-		"peers to exclude from check")
+		"peers or @groups to exclude from check")
+	f.StringVarP(&handshakePeer, "peer", "p", "",
+		"check this peer only (alias, name or routed IP address)")
+	f.BoolVar(&handshakeEach, "each", false,
+		"check every peer, not only the worst one")
 	f.DurationVarP(&handshakeWarn, "warn", "w", 5*time.Minute,
 		"warning threshold")
 	f.DurationVarP(&handshakeCrit, "crit", "c", 15*time.Minute,
 		"critical threshold")
+	f.StringArrayVarP(&handshakeThresholdFlags, "threshold", "t", nil,
+		"thresholds of peer or @group as PEER=WARN:CRIT")
 }
 
 func handshakeResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
-	peer := dump.OldestHandshake(handshakeExclude...)
-	if peer == nil {
-		return errors.New("no valid peer found")
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	thresholds, err := newHandshakeThresholds(idents)
+	if err != nil {
+		return err
+	} else if handshakeEach && handshakePeer == "" {
+		return handshakeEachPeer(dump, thresholds, resp)
+	}
+	return handshakeSummary(dump, thresholds, resp)
+}
+
+func handshakeSummary(dump *wg.Dump, thresholds *handshakeThresholds,
+	resp *monitoringplugin.Response,
+) error {
+	peer, prefix, err := handshakeFindPeer(dump, thresholds)
+	if err != nil {
+		return err
 	} else if never, err := checkNeverHandshake(peer, resp); never {
 		return err
 	}
@@ -52,9 +93,10 @@ func handshakeResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
 	d := time.Since(peer.LatestHandshake).Truncate(time.Second)
 	resp.WithDefaultOkMessage("latest handshake: " + d.String() + " ago")
 
+	warn, crit := thresholds.For(peer)
 	point := monitoringplugin.NewPerformanceDataPoint(
 		"latest handshake", d.Seconds()).SetUnit("s")
-	point.NewThresholds(0, handshakeWarn.Seconds(), 0, handshakeCrit.Seconds())
+	point.NewThresholds(0, warn.Seconds(), 0, crit.Seconds())
 	if err := resp.AddPerformanceDataPoint(point); err != nil {
 		return fmt.Errorf("add performance point %v: %w",
 			peer.LatestHandshake, err)
@@ -62,20 +104,139 @@ func handshakeResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
 
 	if err := outputPeerEndpoint(peer, resp); err != nil {
 		return err
-	} else if resp.GetStatusCode() != monitoringplugin.OK {
+	}
+	outputRoute(handshakePeer, prefix, resp)
+
+	if resp.GetStatusCode() != monitoringplugin.OK {
 		resp.UpdateStatus(resp.GetStatusCode(),
 			"latest handshake: "+d.String()+" ago")
 		var s string
 		if resp.GetStatusCode() == monitoringplugin.WARNING {
-			s = handshakeWarn.String()
+			s = warn.String()
 		} else {
-			s = handshakeCrit.String()
+			s = crit.String()
 		}
 		resp.UpdateStatus(resp.GetStatusCode(), "threshold: "+s)
 	}
 	return nil
 }
 
+func handshakeFindPeer(dump *wg.Dump, thresholds *handshakeThresholds,
+) (*wg.DumpPeer, netip.Prefix, error) {
+	if handshakePeer != "" {
+		return findPeer(dump, thresholds.idents, handshakePeer)
+	}
+
+	peer := handshakeWorstPeer(dump, thresholds)
+	if peer == nil {
+		return nil, netip.Prefix{}, errors.New("no valid peer found")
+	}
+	return peer, netip.Prefix{}, nil
+}
+
+// handshakeWorstPeer returns peer with the worst status against its own
+// thresholds. If multiple peers have the same status, it returns the one with
+// the oldest latest handshake.
+func handshakeWorstPeer(dump *wg.Dump, thresholds *handshakeThresholds,
+) *wg.DumpPeer {
+	var worst *wg.DumpPeer
+	var worstStatus int
+	for i := range dump.Peers {
+		p := &dump.Peers[i]
+		if thresholds.idents.MatchAny(p, handshakeExclude) {
+			continue
+		}
+
+		status := thresholds.Status(p)
+		if worst == nil || status > worstStatus ||
+			(status == worstStatus && p.HandshakeBefore(worst)) {
+			worst, worstStatus = p, status
+		}
+	}
+	return worst
+}
+
+func handshakeEachPeer(dump *wg.Dump, thresholds *handshakeThresholds,
+	resp *monitoringplugin.Response,
+) error {
+	var checked int
+	for i := range dump.Peers {
+		p := &dump.Peers[i]
+		if thresholds.idents.MatchAny(p, handshakeExclude) {
+			continue
+		} else if err := handshakePeerResponse(p, thresholds, resp); err != nil {
+			return err
+		}
+		checked++
+	}
+
+	if checked == 0 {
+		return errors.New("no valid peer found")
+	}
+	resp.WithDefaultOkMessage(fmt.Sprintf(
+		"latest handshake of %d peers within thresholds", checked))
+	return nil
+}
+
+func handshakePeerResponse(peer *wg.DumpPeer, thresholds *handshakeThresholds,
+	resp *monitoringplugin.Response,
+) error {
+	if peer.LatestHandshake.IsZero() {
+		return outputPeerDetails(peer, monitoringplugin.WARNING,
+			"latest handshake: never", resp)
+	}
+
+	d := time.Since(peer.LatestHandshake).Truncate(time.Second)
+	warn, crit := thresholds.For(peer)
+	point := monitoringplugin.NewPerformanceDataPoint(
+		"latest handshake", d.Seconds()).SetUnit("s").SetLabel(peerString(peer))
+	point.NewThresholds(0, warn.Seconds(), 0, crit.Seconds())
+	if err := resp.AddPerformanceDataPoint(uncheckedPoint{point}); err != nil {
+		return fmt.Errorf("add performance point of %v: %w", peer.Name(), err)
+	}
+
+	switch status := point.CheckThresholds(); status {
+	case monitoringplugin.WARNING:
+		return outputPeerDetails(peer, status, "latest handshake: "+d.String()+
+			" ago, threshold: "+warn.String(), resp)
+	case monitoringplugin.CRITICAL:
+		return outputPeerDetails(peer, status, "latest handshake: "+d.String()+
+			" ago, threshold: "+crit.String(), resp)
+	}
+	return nil
+}
+
+// uncheckedPoint is a performance data point with thresholds, which
+// Response.AddPerformanceDataPoint doesn't check, because the caller outputs
+// status of the peer by itself.
+type uncheckedPoint struct {
+	*monitoringplugin.PerformanceDataPoint[float64]
+}
+
+func (uncheckedPoint) HasThresholds() bool { return false }
+
+// outputPeerDetails outputs single line with given message, resolved name and
+// endpoint of peer.
+func outputPeerDetails(peer *wg.DumpPeer, status int, msg string,
+	resp *monitoringplugin.Response,
+) error {
+	peerName, err := peer.ResolvedName()
+	if err != nil {
+		return err
+	} else if peer.Interface != "" {
+		peerName += " on " + peer.Interface
+	}
+
+	epName, err := peer.EndpointName()
+	if err != nil {
+		return err
+	}
+
+	resp.UpdateStatus(status,
+		"peer: "+peerName+", "+msg+", endpoint: "+epName)
+	return nil
+}
+
 func checkNeverHandshake(peer *wg.DumpPeer, resp *monitoringplugin.Response,
 ) (bool, error) {
 	if !peer.LatestHandshake.IsZero() {
@@ -95,6 +256,10 @@ func checkNeverHandshake(peer *wg.DumpPeer, resp *monitoringplugin.Response,
 func outputPeerEndpoint(peer *wg.DumpPeer,
 	resp *monitoringplugin.Response,
 ) error {
+	if peer.Interface != "" {
+		resp.UpdateStatus(resp.GetStatusCode(), "interface: "+peer.Interface)
+	}
+
 	if peerName, err := peer.ResolvedName(); err != nil {
 		return err
 	} else {
@@ -108,3 +273,91 @@ func outputPeerEndpoint(peer *wg.DumpPeer,
 	}
 	return nil
 }
+
+// newHandshakeThresholds parses --threshold flags.
+func newHandshakeThresholds(idents *peerIdents) (*handshakeThresholds, error) {
+	self := &handshakeThresholds{idents: idents}
+	for _, s := range handshakeThresholdFlags {
+		th, err := parseHandshakeThreshold(s)
+		if err != nil {
+			return nil, err
+		} else if err := idents.ValidGroup(th.ID); err != nil {
+			return nil, fmt.Errorf("thresholds %q: %w", s, err)
+		}
+
+		if strings.HasPrefix(th.ID, "@") {
+			self.groups = append(self.groups, th)
+		} else {
+			self.peers = append(self.peers, th)
+		}
+	}
+	return self, nil
+}
+
+func parseHandshakeThreshold(s string) (handshakeThreshold, error) {
+	// public key can end with '=', so cut by the last one.
+	i := strings.LastIndexByte(s, '=')
+	if i < 1 {
+		return handshakeThreshold{}, fmt.Errorf(
+			"invalid thresholds %q, expected PEER=WARN:CRIT", s)
+	}
+	th := handshakeThreshold{ID: s[:i]}
+
+	warn, crit, ok := strings.Cut(s[i+1:], ":")
+	if !ok {
+		return th, fmt.Errorf("invalid thresholds %q, expected PEER=WARN:CRIT", s)
+	}
+
+	var err error
+	if th.Warn, err = time.ParseDuration(warn); err != nil {
+		return th, fmt.Errorf("thresholds %q: parse warning: %w", s, err)
+	} else if th.Crit, err = time.ParseDuration(crit); err != nil {
+		return th, fmt.Errorf("thresholds %q: parse critical: %w", s, err)
+	} else if th.Warn > th.Crit {
+		return th, fmt.Errorf("thresholds %q: warning greater of critical", s)
+	}
+	return th, nil
+}
+
+// handshakeThreshold is warning and critical thresholds of peer or @group.
+type handshakeThreshold struct {
+	ID         string
+	Warn, Crit time.Duration
+}
+
+type handshakeThresholds struct {
+	idents *peerIdents
+	peers  []handshakeThreshold
+	groups []handshakeThreshold
+}
+
+// For returns warning and critical thresholds of peer.
+func (self *handshakeThresholds) For(peer *wg.DumpPeer,
+) (warn, crit time.Duration) {
+	for _, ths := range [...][]handshakeThreshold{self.peers, self.groups} {
+		for _, th := range ths {
+			if self.idents.Match(peer, th.ID) {
+				return th.Warn, th.Crit
+			}
+		}
+	}
+	return handshakeWarn, handshakeCrit
+}
+
+// Status returns status of latest handshake of peer against its thresholds.
+// Peer without handshake has warning status, like checkNeverHandshake outputs.
+func (self *handshakeThresholds) Status(peer *wg.DumpPeer) int {
+	if peer.LatestHandshake.IsZero() {
+		return monitoringplugin.WARNING
+	}
+
+	d := time.Since(peer.LatestHandshake).Truncate(time.Second)
+	warn, crit := self.For(peer)
+	switch {
+	case d > crit:
+		return monitoringplugin.CRITICAL
+	case d > warn:
+		return monitoringplugin.WARNING
+	}
+	return monitoringplugin.OK
+}
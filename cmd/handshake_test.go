@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dsh2dsh/go-monitoringplugin/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
 )
 
 func TestHandshakeResponse(t *testing.T) {
@@ -94,3 +98,249 @@ func TestCheckNeverHandshake(t *testing.T) {
 	t.Log(resp.GetInfo().RawOutput)
 	assert.Contains(t, resp.GetInfo().RawOutput, "latest handshake: never")
 }
+
+func TestHandshakeResponse_interface(t *testing.T) {
+	t.Cleanup(func() { interfaces = nil })
+	interfaces = []string{"wg0", "wg1"}
+
+	dump, err := NewWgDump(
+		[]string{"cat", "../wg/testdata/wg_show_all_dump.txt"})
+	require.NoError(t, err)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(&dump, resp))
+	assert.Contains(t, resp.GetInfo().RawOutput, "interface: wg1")
+	assert.Contains(t, resp.GetInfo().RawOutput, "peer: 10.0.1.2/32")
+}
+
+func TestHandshakeResponse_ipv6Endpoint(t *testing.T) {
+	dump := wg.Dump{Peers: []wg.DumpPeer{{
+		Endpoint:        netip.MustParseAddrPort("[2001:db8::1]:51820"),
+		AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+		LatestHandshake: time.Now(),
+	}}}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(&dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput,
+		"endpoint: [2001:db8::1]:51820")
+
+	dump.Peers[0].Endpoint = netip.AddrPort{}
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(&dump, resp))
+	assert.Contains(t, resp.GetInfo().RawOutput, "endpoint: (none)")
+}
+
+func TestHandshakeResponse_peer(t *testing.T) {
+	t.Cleanup(func() { handshakePeer = "" })
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	for i := range dump.Peers {
+		dump.Peers[i].LatestHandshake = time.Now()
+	}
+	dump.Peers[0].LatestHandshake = time.Now().Add(-handshakeCrit - time.Minute)
+
+	dump.Peers[1].AllowedIPs = append(dump.Peers[1].AllowedIPs,
+		netip.MustParsePrefix("192.168.3.0/24"))
+	handshakePeer = "192.168.3.7"
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput, "peer: 10.0.0.3/32")
+	assert.Contains(t, resp.GetInfo().RawOutput,
+		"route: 192.168.3.7 via 192.168.3.0/24")
+
+	handshakePeer = dump.Peers[1].PublicKey
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput, "peer: 10.0.0.3/32")
+	assert.NotContains(t, resp.GetInfo().RawOutput, "route:")
+
+	handshakePeer = "10.0.0.2/32"
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	assert.NotContains(t, resp.GetInfo().RawOutput, "route:")
+
+	handshakePeer = "10.0.0.10"
+	resp = monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, handshakeResponse(dump, resp),
+		"peer not found: 10.0.0.10")
+}
+
+func TestHandshakeResponse_each(t *testing.T) {
+	t.Cleanup(func() { handshakeEach, handshakeExclude = false, nil })
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.Peers[0].LatestHandshake = time.Now()
+	dump.Peers[1].LatestHandshake = time.Now().Add(-handshakeWarn - time.Minute)
+	dump.Peers[2].LatestHandshake = time.Now().Add(-handshakeCrit - time.Minute)
+	dump.Peers[3].LatestHandshake = time.Time{}
+
+	handshakeEach = true
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	outputLines, _, _ := strings.Cut(output, " | ")
+	assert.Equal(t, []string{
+		"CRITICAL: peer: 10.0.0.4/32, latest handshake: 16m0s ago, threshold: 15m0s, endpoint: 10.0.0.1:54323",
+		"peer: 10.0.0.3/32, latest handshake: 6m0s ago, threshold: 5m0s, endpoint: 10.0.0.1:54322",
+		"peer: 10.0.0.5/32, latest handshake: never, endpoint: 10.0.0.1:54324",
+	}, strings.Split(outputLines, "\n"))
+
+	assert.Contains(t, output, " 'latest handshake_10.0.0.2/32'=0s;300;900;;")
+	assert.Contains(t, output, " 'latest handshake_10.0.0.3/32'=360s;300;900;;")
+	assert.Contains(t, output, " 'latest handshake_10.0.0.4/32'=960s;300;900;;")
+	assert.NotContains(t, output, "'latest handshake_10.0.0.5/32'")
+	assert.NotContains(t, output, "'latest handshake'=")
+
+	handshakeExclude = []string{"10.0.0.4/32", "10.0.0.5/32"}
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	assert.Contains(t, output, "peer: 10.0.0.3/32, latest handshake: 6m0s ago")
+	assert.NotContains(t, output, "10.0.0.4/32")
+}
+
+func TestHandshakeThresholds(t *testing.T) {
+	t.Cleanup(func() { handshakeThresholdFlags = nil })
+	idents := usePeerIdents(t,
+		[]string{"laptop=CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA="},
+		[]string{
+			"roaming=laptop,10.0.0.4/32",
+			"sites=10.0.0.2/32,10.0.0.4/32",
+		})
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+
+	handshakeThresholdFlags = []string{
+		"@roaming=24h:72h",
+		"@sites=1m:2m",
+		"laptop=1h:2h",
+		"EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA==10s:20s",
+	}
+	thresholds, err := newHandshakeThresholds(idents)
+	require.NoError(t, err)
+
+	tests := []struct {
+		peer       int
+		warn, crit time.Duration
+	}{
+		{peer: 0, warn: time.Minute, crit: 2 * time.Minute},
+		{peer: 1, warn: time.Hour, crit: 2 * time.Hour},
+		{peer: 2, warn: 24 * time.Hour, crit: 72 * time.Hour},
+		{peer: 3, warn: 10 * time.Second, crit: 20 * time.Second},
+	}
+	for _, tt := range tests {
+		warn, crit := thresholds.For(&dump.Peers[tt.peer])
+		assert.Equal(t, tt.warn, warn, dump.Peers[tt.peer].Name())
+		assert.Equal(t, tt.crit, crit, dump.Peers[tt.peer].Name())
+	}
+
+	handshakeThresholdFlags = nil
+	thresholds, err = newHandshakeThresholds(idents)
+	require.NoError(t, err)
+	warn, crit := thresholds.For(&dump.Peers[0])
+	assert.Equal(t, handshakeWarn, warn)
+	assert.Equal(t, handshakeCrit, crit)
+}
+
+func TestHandshakeThresholds_errors(t *testing.T) {
+	t.Cleanup(func() { handshakeThresholdFlags = nil })
+	idents := usePeerIdents(t, nil, nil)
+
+	tests := []struct {
+		threshold string
+		wantErr   string
+	}{
+		{threshold: "10.0.0.2/32", wantErr: "expected PEER=WARN:CRIT"},
+		{threshold: "=1m:2m", wantErr: "expected PEER=WARN:CRIT"},
+		{threshold: "10.0.0.2/32=1m", wantErr: "expected PEER=WARN:CRIT"},
+		{threshold: "10.0.0.2/32=X:2m", wantErr: "parse warning"},
+		{threshold: "10.0.0.2/32=1m:X", wantErr: "parse critical"},
+		{threshold: "10.0.0.2/32=2m:1m", wantErr: "warning greater of critical"},
+		{threshold: "@sites=1m:2m", wantErr: "unknown group: sites"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.threshold, func(t *testing.T) {
+			handshakeThresholdFlags = []string{tt.threshold}
+			_, err := newHandshakeThresholds(idents)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandshakeResponse_thresholds(t *testing.T) {
+	t.Cleanup(func() {
+		handshakeEach, handshakeExclude, handshakeThresholdFlags = false, nil, nil
+	})
+	usePeerIdents(t, []string{"router=10.0.0.2/32"},
+		[]string{"roaming=10.0.0.4/32,10.0.0.5/32"})
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	for i := range dump.Peers {
+		dump.Peers[i].LatestHandshake = time.Now().Add(-time.Hour)
+	}
+	dump.Peers[1].LatestHandshake = time.Now()
+
+	handshakeEach = true
+	handshakeExclude = []string{"10.0.0.3/32"}
+	handshakeThresholdFlags = []string{"router=2h:3h", "@roaming=24h:48h"}
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "OK: latest handshake of 3 peers within thresholds")
+	assert.Regexp(t, ` 'latest handshake_10.0.0.2/32'=360\ds;7200;10800;;`,
+		output)
+	assert.Regexp(t, ` 'latest handshake_10.0.0.4/32'=360\ds;86400;172800;;`,
+		output)
+	assert.NotContains(t, output, "'latest handshake_10.0.0.3/32'")
+
+	handshakeExclude = []string{"@roaming"}
+	handshakeThresholdFlags = []string{"router=10m:3h"}
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "threshold: 10m0s")
+	assert.Contains(t, output, " 'latest handshake_10.0.0.3/32'=")
+	assert.NotContains(t, output, "'latest handshake_10.0.0.4/32'")
+}
+
+func TestHandshakeResponse_worstPeer(t *testing.T) {
+	t.Cleanup(func() { handshakeThresholdFlags = nil })
+	usePeerIdents(t, []string{"router=10.0.0.2/32"},
+		[]string{"roaming=10.0.0.4/32,10.0.0.5/32"})
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	for i := range dump.Peers {
+		dump.Peers[i].LatestHandshake = time.Now()
+	}
+	dump.Peers[0].LatestHandshake = time.Now().Add(-10 * time.Minute)
+	dump.Peers[2].LatestHandshake = time.Now().Add(-time.Hour)
+
+	handshakeThresholdFlags = []string{"router=1m:5m", "@roaming=24h:48h"}
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "peer: 10.0.0.2/32")
+	assert.Contains(t, output, "threshold: 5m0s")
+	assert.Regexp(t, ` 'latest handshake'=60\ds;60;300;;`, output)
+
+	handshakeThresholdFlags = []string{"router=1h:2h", "@roaming=24h:48h"}
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, handshakeResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	assert.Contains(t, output, "peer: 10.0.0.4/32")
+	assert.Regexp(t, ` 'latest handshake'=360\ds;86400;172800;;`, output)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	interfaceListenPort uint16
+	interfaceFwMark     string
+	interfacePublicKey  string
+
+	interfaceCmd = cobra.Command{
+		Use:   "interface [--listen-port N] [--fwmark N] [--public-key KEY] [wg show wg0 dump]",
+		Short: "check listen port, fwmark and keys of interface",
+		Long: `It compares listen port, fwmark and public key of interface with expected
+values and outputs critical status if listen port or public key differs, and
+warning status if fwmark differs. Every value is checked only if it's given.
+Fwmark can be given as decimal or hex number, or as off.
+
+If dump contains private key, it verifies the private key derives to public key
+of the interface and outputs critical status if it doesn't.
+
+Dump must be of single interface, like wg show wg0 dump or -i wg0.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("interface", args, interfaceResponse))
+		},
+	}
+)
+
+func init() {
+	f := interfaceCmd.Flags()
+	f.Uint16Var(&interfaceListenPort, "listen-port", 0, "expected listen port")
+	f.StringVar(&interfaceFwMark, "fwmark", "", "expected fwmark or off")
+	f.StringVar(&interfacePublicKey, "public-key", "", "expected public key")
+}
+
+func interfaceResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	if err := singleInterface(dump); err != nil {
+		return err
+	}
+
+	fwMark, err := expectedFwMark()
+	if err != nil {
+		return err
+	}
+
+	listenPort := strconv.FormatUint(uint64(dump.ListenPort), 10)
+	resp.WithDefaultOkMessage(fmt.Sprintf(
+		"public key: %s, listen port: %s, fwmark: %s",
+		dump.PublicKey, listenPort, wg.FormatFwMark(dump.FwMark)))
+
+	if interfacePublicKey != "" && interfacePublicKey != dump.PublicKey {
+		resp.UpdateStatus(monitoringplugin.CRITICAL, fmt.Sprintf(
+			"public key: expected %s, running %s", interfacePublicKey,
+			dump.PublicKey))
+	}
+
+	if dump.PrivateKey != "" {
+		if err := checkPrivateKey(dump, resp); err != nil {
+			return err
+		}
+	}
+
+	if interfaceListenPort != 0 && interfaceListenPort != dump.ListenPort {
+		resp.UpdateStatus(monitoringplugin.CRITICAL, fmt.Sprintf(
+			"listen port: expected %d, running %s", interfaceListenPort, listenPort))
+	}
+
+	if interfaceFwMark != "" && fwMark != dump.FwMark {
+		resp.UpdateStatus(monitoringplugin.WARNING, fmt.Sprintf(
+			"fwmark: expected %s, running %s", wg.FormatFwMark(fwMark),
+			wg.FormatFwMark(dump.FwMark)))
+	}
+	return nil
+}
+
+// checkPrivateKey outputs critical status if private key of interface doesn't
+// derive to its public key.
+func checkPrivateKey(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	privateKey, err := wg.ParseKey(dump.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("private key of interface: %w", err)
+	}
+
+	publicKey, err := privateKey.PublicKey()
+	if err != nil {
+		return fmt.Errorf("private key of interface: %w", err)
+	} else if publicKey.String() != dump.PublicKey {
+		resp.UpdateStatus(monitoringplugin.CRITICAL, fmt.Sprintf(
+			"private key derives to %s, not to public key %s", publicKey,
+			dump.PublicKey))
+	}
+	return nil
+}
+
+// expectedFwMark parses --fwmark, if given.
+func expectedFwMark() (uint32, error) {
+	if interfaceFwMark == "" || interfaceFwMark == "off" {
+		return 0, nil
+	}
+
+	fwMark, err := strconv.ParseUint(interfaceFwMark, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("parse fwmark %q: %w", interfaceFwMark, err)
+	}
+	return uint32(fwMark), nil
+}
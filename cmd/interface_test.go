@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+const (
+	testPrivateKey = "9HeG4vaXIRq7lWtnXMKRJSNV/NhwyZCOEkZ9o3k06Do="
+	testPublicKey  = "E3zbJ+eE37IcGQWorbKgu56e/ZZSzwUz5scVmjRa3UM="
+)
+
+func useInterfaceExpect(t *testing.T, listenPort uint16, fwMark, publicKey string,
+) {
+	t.Helper()
+	interfaceListenPort, interfaceFwMark, interfacePublicKey = listenPort,
+		fwMark, publicKey
+	t.Cleanup(func() {
+		interfaceListenPort, interfaceFwMark, interfacePublicKey = 0, "", ""
+	})
+}
+
+func TestInterfaceResponse(t *testing.T) {
+	dump := &wg.Dump{
+		PrivateKey: testPrivateKey,
+		PublicKey:  testPublicKey,
+		ListenPort: 51820,
+		FwMark:     0x10,
+	}
+
+	tests := []struct {
+		name       string
+		listenPort uint16
+		fwMark     string
+		publicKey  string
+		privateKey string
+		status     int
+		output     string
+	}{
+		{
+			name:   "without expectations",
+			status: monitoringplugin.OK,
+			output: "OK: public key: " + testPublicKey +
+				", listen port: 51820, fwmark: 0x10",
+		},
+		{
+			name:       "expected",
+			listenPort: 51820,
+			fwMark:     "16",
+			publicKey:  testPublicKey,
+			status:     monitoringplugin.OK,
+		},
+		{
+			name:       "listen port",
+			listenPort: 12345,
+			status:     monitoringplugin.CRITICAL,
+			output:     "listen port: expected 12345, running 51820",
+		},
+		{
+			name:   "fwmark",
+			fwMark: "off",
+			status: monitoringplugin.WARNING,
+			output: "fwmark: expected off, running 0x10",
+		},
+		{
+			name:      "public key",
+			publicKey: "tIByxB0qwbGzOxWJmAbXQ8tNauSuLbE4DYruQgWD0lM=",
+			status:    monitoringplugin.CRITICAL,
+			output:    "public key: expected tIByxB0qwbGzOxWJmAbXQ8tNauSuLbE4DYruQgWD0lM=, running " + testPublicKey,
+		},
+		{
+			name:       "private key",
+			privateKey: "HkZOd6DqjVoJnXPqwDw69Il3QQZgf+RLFaqiq8yArYc=",
+			status:     monitoringplugin.CRITICAL,
+			output:     "private key derives to tIByxB0qwbGzOxWJmAbXQ8tNauSuLbE4DYruQgWD0lM=, not to public key " + testPublicKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useInterfaceExpect(t, tt.listenPort, tt.fwMark, tt.publicKey)
+			dump := *dump
+			if tt.privateKey != "" {
+				dump.PrivateKey = tt.privateKey
+			}
+
+			resp := monitoringplugin.NewResponse("test OK")
+			require.NoError(t, interfaceResponse(&dump, resp))
+			assert.Equal(t, tt.status, resp.GetStatusCode())
+			output := resp.GetInfo().RawOutput
+			t.Log(output)
+			assert.Contains(t, output, tt.output)
+		})
+	}
+}
+
+func TestInterfaceResponse_errors(t *testing.T) {
+	resp := monitoringplugin.NewResponse("test OK")
+	err := interfaceResponse(&wg.Dump{Multiple: true}, resp)
+	require.ErrorContains(t, err, "peers of multiple interfaces")
+
+	dump := &wg.Dump{PrivateKey: "foobar", PublicKey: testPublicKey}
+	err = interfaceResponse(dump, resp)
+	require.ErrorContains(t, err, "private key of interface")
+
+	useInterfaceExpect(t, 0, "foo", "")
+	err = interfaceResponse(&wg.Dump{PublicKey: testPublicKey}, resp)
+	require.ErrorContains(t, err, "parse fwmark \"foo\"")
+}
+
+func TestInterfaceResponse_noKeys(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.PrivateKey, dump.PublicKey = "", ""
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, interfaceResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+}
+
+func TestInterfaceResponse_uapi(t *testing.T) {
+	t.Cleanup(func() { uapiSockets = nil })
+	uapiSockets = []string{filepath.Join(t.TempDir(), "wg0.sock")}
+	serveUAPI(t, uapiSockets[0], "../wg/testdata/uapi_get.txt")
+
+	dump, err := NewWgDump(nil)
+	require.NoError(t, err)
+	useInterfaceExpect(t, 0, "", "Y2FD6uTgrq+/bbBAXCNOtE+PGk8Papoh7qaWBpQUXn0=")
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, interfaceResponse(&dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	inventoryExpect []string
+	inventoryFile   string
+
+	inventoryCmd = cobra.Command{
+		Use:   "inventory [-e [NAME=]KEY]... [-f FILE] [wg show wg0 dump]",
+		Short: "check peers against expected list of public keys",
+		Long: `It compares public keys of peers with expected list of public keys and
+outputs critical status if some expected peer is missing and warning status if
+some peer is not expected, with its allowed IPs.
+
+Expected peers are given by -e or by -f, which reads a file with one peer per
+line. Empty lines and lines starting with # are ignored. Every peer can be given
+as public key or as NAME=KEY, where NAME is used for reporting missing peer.
+Peers without NAME are reported using their --alias, if any, or by fingerprint
+of public key.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("inventory", args, inventoryResponse))
+		},
+	}
+)
+
+func init() {
+	f := inventoryCmd.Flags()
+	f.StringArrayVarP(&inventoryExpect, "expect", "e", nil,
+		"expected peer as public key or NAME=KEY")
+	f.StringVarP(&inventoryFile, "file", "f", "",
+		"read expected peers from this file, one per line")
+}
+
+// expectedPeer is a peer from expected list.
+type expectedPeer struct {
+	Name string
+	Key  string
+}
+
+func inventoryResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	expected, err := readExpectedPeers()
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]struct{}, len(dump.Peers))
+	for i := range dump.Peers {
+		present[dump.Peers[i].PublicKey] = struct{}{}
+	}
+
+	var missing []expectedPeer
+	expectedKeys := make(map[string]struct{}, len(expected))
+	for _, p := range expected {
+		expectedKeys[p.Key] = struct{}{}
+		if _, ok := present[p.Key]; !ok {
+			missing = append(missing, p)
+		}
+	}
+
+	var unknown []*wg.DumpPeer
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		if _, ok := expectedKeys[peer.PublicKey]; !ok {
+			unknown = append(unknown, peer)
+		}
+	}
+
+	resp.WithDefaultOkMessage(fmt.Sprintf("all %d expected peers present",
+		len(expected)))
+	if err := inventoryPerfData(len(expected), len(missing), len(unknown),
+		resp); err != nil {
+		return err
+	}
+
+	for _, p := range missing {
+		name := p.Name
+		if name == "" {
+			name = idents.Alias(&wg.DumpPeer{PublicKey: p.Key})
+		}
+		if name == "" {
+			resp.UpdateStatus(monitoringplugin.CRITICAL,
+				"missing peer: "+wg.KeyFingerprint(p.Key))
+		} else {
+			resp.UpdateStatus(monitoringplugin.CRITICAL,
+				"missing peer: "+name+" ("+wg.KeyFingerprint(p.Key)+")")
+		}
+	}
+
+	for _, peer := range unknown {
+		resp.UpdateStatus(monitoringplugin.WARNING, unknownPeerString(peer))
+	}
+	return nil
+}
+
+func inventoryPerfData(expected, missing, unknown int,
+	resp *monitoringplugin.Response,
+) error {
+	missingPoint := monitoringplugin.NewPerformanceDataPoint("missing", missing)
+	missingPoint.NewThresholds(0, 0, 0, 0).UseWarning(false, false)
+	unknownPoint := monitoringplugin.NewPerformanceDataPoint("unknown", unknown)
+	unknownPoint.NewThresholds(0, 0, 0, 0).UseCritical(false, false)
+
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		monitoringplugin.NewPerformanceDataPoint("expected", expected),
+		missingPoint,
+		unknownPoint,
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
+
+func unknownPeerString(peer *wg.DumpPeer) string {
+	var b strings.Builder
+	b.WriteString("unknown peer: " + peer.PublicKey)
+	if peer.Interface != "" {
+		b.WriteString(" on " + peer.Interface)
+	}
+
+	b.WriteString(", allowed ips: ")
+	if len(peer.AllowedIPs) == 0 {
+		b.WriteString("(none)")
+	}
+	for i, prefix := range peer.AllowedIPs {
+		if i != 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(prefix.String())
+	}
+	return b.String()
+}
+
+// readExpectedPeers returns expected peers from --expect and --file.
+func readExpectedPeers() ([]expectedPeer, error) {
+	lines := inventoryExpect
+	if inventoryFile != "" {
+		fileLines, err := readExpectedFile(inventoryFile)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines[:len(lines):len(lines)], fileLines...)
+	}
+
+	if len(lines) == 0 {
+		return nil, errors.New("no expected peers given")
+	}
+
+	expected := make([]expectedPeer, 0, len(lines))
+	seen := make(map[string]struct{}, len(lines))
+	for i, s := range lines {
+		p, err := parseExpectedPeer(s)
+		if err != nil {
+			return nil, fmt.Errorf("expected peer #%d: %w", i+1, err)
+		} else if _, ok := seen[p.Key]; ok {
+			return nil, fmt.Errorf("duplicate expected peer: %s", p.Key)
+		}
+		seen[p.Key] = struct{}{}
+		expected = append(expected, p)
+	}
+	return expected, nil
+}
+
+// parseExpectedPeer parses KEY or NAME=KEY. Public keys end with '=', so the
+// whole string is a key, if nothing follows the first '='.
+func parseExpectedPeer(s string) (expectedPeer, error) {
+	p := expectedPeer{Key: s}
+	if name, key, ok := strings.Cut(s, "="); ok && key != "" {
+		p = expectedPeer{Name: name, Key: key}
+	}
+
+	if _, err := wg.ParseKey(p.Key); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func readExpectedFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open expected peers: %w", err)
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s := strings.TrimSpace(scanner.Text())
+		if s != "" && !strings.HasPrefix(s, "#") {
+			lines = append(lines, s)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read expected peers from %q: %w", name, err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func useInventory(t *testing.T, expect []string, file string) {
+	t.Helper()
+	inventoryExpect, inventoryFile = expect, file
+	t.Cleanup(func() { inventoryExpect, inventoryFile = nil, "" })
+}
+
+func TestInventoryResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	var keys []string
+	for i := range dump.Peers {
+		keys = append(keys, dump.Peers[i].PublicKey)
+	}
+	useInventory(t, keys, "")
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, inventoryResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: all 4 expected peers present | 'expected'=4 'missing'=0;;0;; 'unknown'=0;0;;;",
+		resp.GetInfo().RawOutput)
+}
+
+func TestInventoryResponse_drift(t *testing.T) {
+	usePeerIdents(t,
+		[]string{"laptop=YYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYA="}, nil)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.Peers[3].AllowedIPs = append(dump.Peers[3].AllowedIPs,
+		netip.MustParsePrefix("192.168.0.0/16"))
+	dump.Peers[3].Interface = "wg1"
+	dump.Peers[2].AllowedIPs = nil
+
+	useInventory(t, []string{
+		dump.Peers[0].PublicKey,
+		"router=" + dump.Peers[1].PublicKey,
+		"server=ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZA=",
+		"YYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYA=",
+		"XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXA=",
+	}, "")
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, inventoryResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"\nmissing peer: server (ZZZZZZZZ)\n")
+	assert.Contains(t, output,
+		"\nmissing peer: laptop (YYYYYYYY)\n")
+	assert.Contains(t, output, "\nmissing peer: XXXXXXXX\n")
+	assert.Contains(t, output, "\nunknown peer: "+dump.Peers[2].PublicKey+
+		", allowed ips: (none)\n")
+	assert.Contains(t, output, "\nunknown peer: "+dump.Peers[3].PublicKey+
+		" on wg1, allowed ips: 10.0.0.5/32, 192.168.0.0/16 |")
+	assert.Contains(t, output,
+		" | 'expected'=5 'missing'=3;;0;; 'unknown'=2;0;;;")
+}
+
+func TestInventoryResponse_file(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	name := filepath.Join(t.TempDir(), "peers.txt")
+	require.NoError(t, os.WriteFile(name, []byte(`# expected peers
+router=`+dump.Peers[1].PublicKey+`
+
+  `+dump.Peers[2].PublicKey+`
+`), 0o600))
+	useInventory(t, []string{dump.Peers[0].PublicKey}, name)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, inventoryResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "\nunknown peer: "+dump.Peers[3].PublicKey)
+	assert.Contains(t, output, " | 'expected'=3 'missing'=0;;0;; 'unknown'=1;0;;;")
+}
+
+func TestInventoryResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+
+	tests := []struct {
+		name    string
+		expect  []string
+		file    string
+		wantErr string
+	}{
+		{name: "empty", wantErr: "no expected peers given"},
+		{
+			name: "duplicate",
+			expect: []string{
+				"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+				"foo=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+			},
+			wantErr: "duplicate expected peer: AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		},
+		{
+			name:    "invalid key",
+			expect:  []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", "foo=bar"},
+			wantErr: "expected peer #2: invalid key",
+		},
+		{
+			name:    "file",
+			file:    filepath.Join(t.TempDir(), "peers.txt"),
+			wantErr: "open expected peers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useInventory(t, tt.expect, tt.file)
+			resp := monitoringplugin.NewResponse("test OK")
+			require.ErrorContains(t, inventoryResponse(dump, resp), tt.wantErr)
+		})
+	}
+
+	peerAliases = []string{"foo"}
+	t.Cleanup(func() { peerAliases = nil })
+	resp := monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, inventoryResponse(dump, resp), "invalid alias")
+}
+
+func TestParseExpectedPeer(t *testing.T) {
+	const key = "YYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYA="
+	p, err := parseExpectedPeer(key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedPeer{Key: key}, p)
+
+	p, err = parseExpectedPeer("laptop=" + key)
+	require.NoError(t, err)
+	assert.Equal(t, expectedPeer{Name: "laptop", Key: key}, p)
+
+	_, err = parseExpectedPeer("foo")
+	require.ErrorContains(t, err, "invalid key")
+	_, err = parseExpectedPeer("laptop=" + key[1:])
+	require.ErrorContains(t, err, "invalid key")
+}
+
+func TestUnknownPeerString(t *testing.T) {
+	peer := &wg.DumpPeer{
+		PublicKey: "A",
+		AllowedIPs: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.2/32"),
+			netip.MustParsePrefix("fd00::2/128"),
+		},
+	}
+	assert.Equal(t, "unknown peer: A, allowed ips: 10.0.0.2/32, fd00::2/128",
+		unknownPeerString(peer))
+}
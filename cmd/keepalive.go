@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	keepaliveMin     time.Duration
+	keepaliveMax     time.Duration
+	keepaliveRequire []string
+	keepaliveForbid  []string
+	keepaliveExclude []string
+	keepaliveState   string
+
+	keepaliveCmd = cobra.Command{
+		Use:   "keepalive [--min 15s] [--max 60s] [-r peer]... [-n peer]... [--state FILE] [wg show wg0 dump]",
+		Short: "check persistent keepalive of peers against policy",
+		Long: `It checks persistent keepalive of every peer and outputs warning status for
+every peer, which violates the policy:
+
+  * keepalive of peer, if set, must be between --min and --max;
+  * peers or @groups, given by -r, like peers behind NAT, must set keepalive;
+  * peers or @groups, given by -n, like server-side peers, must not set
+    keepalive.
+
+With --state it saves endpoints of peers into given file and treats every peer,
+which endpoint port changed since previous run, as behind NAT, so it must set
+keepalive too. Once detected, NAT is remembered in the state.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("keepalive", args, keepaliveResponse))
+		},
+	}
+)
+
+func init() {
+	f := keepaliveCmd.Flags()
+	f.DurationVar(&keepaliveMin, "min", 15*time.Second,
+		"minimal keepalive of peer, if set")
+	f.DurationVar(&keepaliveMax, "max", time.Minute,
+		"maximal keepalive of peer, if set")
+	f.StringArrayVarP(&keepaliveRequire, "require", "r", nil,
+		"peers or @groups, which must set keepalive")
+	f.StringArrayVarP(&keepaliveForbid, "forbid", "n", nil,
+		"peers or @groups, which must not set keepalive")
+	f.StringArrayVarP(&keepaliveExclude, "exclude", "x", nil,
+		"peers or @groups to exclude from check")
+	f.StringVar(&keepaliveState, "state", "",
+		"file with endpoints of previous run for detecting NAT")
+}
+
+func keepaliveResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	} else if err := checkKeepaliveFlags(idents); err != nil {
+		return err
+	}
+
+	nat, err := keepaliveNAT(dump)
+	if err != nil {
+		return err
+	}
+
+	var checked, violations int
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		if idents.MatchAny(peer, keepaliveExclude) {
+			continue
+		}
+		checked++
+
+		msg := keepaliveViolation(peer, idents, nat[peer])
+		if msg == "" {
+			continue
+		}
+		violations++
+		if err := outputPeerDetails(peer, monitoringplugin.WARNING, msg,
+			resp); err != nil {
+			return err
+		}
+	}
+
+	resp.WithDefaultOkMessage(fmt.Sprintf(
+		"keepalive of %d peers matches policy", checked))
+	return keepalivePerfData(violations, len(nat), resp)
+}
+
+func checkKeepaliveFlags(idents *peerIdents) error {
+	if keepaliveMin > keepaliveMax {
+		return errors.New("--min greater of --max")
+	}
+
+	for _, ids := range [...][]string{keepaliveRequire, keepaliveForbid} {
+		for _, id := range ids {
+			if err := idents.ValidGroup(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepaliveViolation returns description of policy violation by peer or empty
+// string.
+func keepaliveViolation(peer *wg.DumpPeer, idents *peerIdents, nat bool,
+) string {
+	switch {
+	case idents.MatchAny(peer, keepaliveForbid):
+		if peer.Keepalive != 0 {
+			return "keepalive: " + peer.Keepalive.String() + ", expected: off"
+		}
+	case peer.Keepalive == 0:
+		if nat {
+			return "keepalive: off, expected: " + keepaliveRange() + " behind NAT"
+		} else if idents.MatchAny(peer, keepaliveRequire) {
+			return "keepalive: off, expected: " + keepaliveRange()
+		}
+	case peer.Keepalive < keepaliveMin || peer.Keepalive > keepaliveMax:
+		return "keepalive: " + peer.Keepalive.String() + ", expected: " +
+			keepaliveRange()
+	}
+	return ""
+}
+
+func keepaliveRange() string {
+	return keepaliveMin.String() + ".." + keepaliveMax.String()
+}
+
+// keepaliveNAT returns peers behind NAT, detected by changes of their endpoint
+// ports since previous run, and saves endpoints of peers into --state.
+func keepaliveNAT(dump *wg.Dump) (map[*wg.DumpPeer]bool, error) {
+	if keepaliveState == "" {
+		return nil, nil
+	}
+
+	nat := make(map[*wg.DumpPeer]bool)
+	err := withState(keepaliveState, "keepalive", func(state *checkState) error {
+		now := time.Now()
+		for i := range dump.Peers {
+			peer := &dump.Peers[i]
+			cur := newPeerState(peer, now)
+			if prev := state.Peer(peer); prev != nil {
+				cur.NAT = prev.NAT || portChanged(prev.Endpoint, cur.Endpoint)
+			}
+			if cur.NAT {
+				nat[peer] = true
+			}
+			state.SetPeer(peer, cur)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nat, nil
+}
+
+// portChanged returns true if both endpoints are known and their ports differ.
+func portChanged(prev, cur netip.AddrPort) bool {
+	return prev.IsValid() && cur.IsValid() && prev.Port() != cur.Port()
+}
+
+func keepalivePerfData(violations, nat int, resp *monitoringplugin.Response,
+) error {
+	points := []*monitoringplugin.PerformanceDataPoint[int]{
+		monitoringplugin.NewPerformanceDataPoint("violations", violations),
+	}
+	if keepaliveState != "" {
+		points = append(points,
+			monitoringplugin.NewPerformanceDataPoint("behind nat", nat))
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useKeepalivePolicy(t *testing.T, required, forbid []string, state string) {
+	t.Helper()
+	keepaliveRequire, keepaliveForbid, keepaliveState = required, forbid, state
+	t.Cleanup(func() {
+		keepaliveRequire, keepaliveForbid, keepaliveState = nil, nil, ""
+		keepaliveMin, keepaliveMax = 15*time.Second, time.Minute
+		keepaliveExclude = nil
+	})
+}
+
+func TestKeepaliveResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useKeepalivePolicy(t, []string{"10.0.0.2/32"}, nil, "")
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, keepaliveResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: keepalive of 4 peers matches policy | 'violations'=0",
+		resp.GetInfo().RawOutput)
+}
+
+func TestKeepaliveResponse_violations(t *testing.T) {
+	usePeerIdents(t, nil, []string{"servers=10.0.0.2/32,10.0.0.3/32"})
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.Peers[2].Keepalive = 5 * time.Second
+	useKeepalivePolicy(t, []string{"10.0.0.5/32"}, []string{"@servers"}, "")
+	keepaliveExclude = []string{"10.0.0.3/32"}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, keepaliveResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"peer: 10.0.0.2/32, keepalive: 15s, expected: off")
+	assert.Contains(t, output,
+		"peer: 10.0.0.4/32, keepalive: 5s, expected: 15s..1m0s")
+	assert.Contains(t, output,
+		"peer: 10.0.0.5/32, keepalive: off, expected: 15s..1m0s")
+	assert.NotContains(t, output, "10.0.0.3/32")
+	assert.Contains(t, output, "'violations'=3")
+}
+
+func TestKeepaliveResponse_nat(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	useKeepalivePolicy(t, nil, nil, stateFile)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, keepaliveResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput, "'behind nat'=0")
+
+	dump.Peers[1].Endpoint = netip.MustParseAddrPort("10.0.0.1:60000")
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, keepaliveResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"peer: 10.0.0.3/32, keepalive: off, expected: 15s..1m0s behind NAT")
+	assert.Contains(t, output, "'behind nat'=1")
+
+	// NAT is remembered, even if port didn't change.
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, keepaliveResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+
+	state, err := loadState(stateFile)
+	require.NoError(t, err)
+	st := state.Peer(&dump.Peers[1])
+	require.NotNil(t, st)
+	assert.True(t, st.NAT)
+	assert.Equal(t, dump.Peers[1].Endpoint, st.Endpoint)
+}
+
+func TestKeepaliveResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useKeepalivePolicy(t, []string{"@foo"}, nil, "")
+	err := keepaliveResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "foo")
+
+	useKeepalivePolicy(t, nil, nil, "")
+	keepaliveMin = 2 * time.Minute
+	err = keepaliveResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "--min greater of --max")
+}
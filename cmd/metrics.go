@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsOutput string
+
+	metricsCmd = cobra.Command{
+		Use:   "metrics [-o FILE] [wg show all dump]",
+		Short: "write Prometheus metrics for textfile collector",
+		Long: `It reads wireguard dump and writes metrics of interfaces and peers in
+Prometheus format into given file, like node_exporter's textfile collector
+expects, or into stdout.
+
+The file is replaced atomically, so the collector never reads partially written
+metrics. If the dump can't be read, it writes up metric with 0 value
+only and exits with error.`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeMetrics(args)
+		},
+	}
+)
+
+func init() {
+	f := metricsCmd.Flags()
+	f.StringVarP(&metricsOutput, "output", "o", "",
+		"write metrics into this file instead of stdout, like /var/lib/node_exporter/wireguard.prom")
+	addPromFlags(f)
+}
+
+func writeMetrics(args []string) error {
+	prom, err := newPromMetrics()
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	dump, dumpErr := NewWgDump(args)
+	if dumpErr != nil {
+		err = prom.Write(&b, nil)
+	} else {
+		err = prom.Write(&b, &dump)
+	}
+	if err != nil {
+		return err
+	}
+
+	if metricsOutput == "" {
+		if _, err := b.WriteTo(os.Stdout); err != nil {
+			return fmt.Errorf("write metrics to stdout: %w", err)
+		}
+	} else if err := writeFileAtomic(metricsOutput, b.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return dumpErr
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	metricsOutput = filepath.Join(t.TempDir(), "wireguard.prom")
+	t.Cleanup(func() { metricsOutput = "" })
+
+	require.NoError(t, writeMetrics(nil))
+	fi, err := os.Stat(metricsOutput)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), fi.Mode().Perm())
+
+	b, err := os.ReadFile(metricsOutput)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "\nwireguard_up 1\n")
+	assert.NotContains(t, string(b), "wireguard_interface_peers")
+
+	entries, err := os.ReadDir(filepath.Dir(metricsOutput))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestWriteMetrics_dumpErr(t *testing.T) {
+	wantErr := errors.New("test error")
+	useSource(t, SourceFunc(func(args []string) (wg.Dump, error) {
+		return wg.Dump{}, wantErr
+	}))
+	metricsOutput = filepath.Join(t.TempDir(), "wireguard.prom")
+	t.Cleanup(func() { metricsOutput = "" })
+
+	require.ErrorIs(t, writeMetrics(nil), wantErr)
+	b, err := os.ReadFile(metricsOutput)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "\nwireguard_up 0\n")
+	assert.NotContains(t, string(b), "wireguard_peer_")
+}
+
+func TestWriteMetrics_errors(t *testing.T) {
+	useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	t.Cleanup(func() { metricsOutput, promPrefix = "", "wireguard" })
+
+	metricsOutput = filepath.Join(t.TempDir(), "foo", "wireguard.prom")
+	require.ErrorContains(t, writeMetrics(nil), "create temp file")
+
+	promPrefix = ""
+	require.ErrorContains(t, writeMetrics(nil), "invalid metric prefix")
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	overlapAcross        bool
+	overlapIdenticalOnly bool
+
+	overlapCmd = cobra.Command{
+		Use:   "overlap [--across] [--identical-only] [wg show wg0 dump]",
+		Short: "check allowed IPs of peers for conflicts",
+		Long: `It compares allowed IPs of every peer with allowed IPs of other peers and
+outputs critical status if some prefix claimed by multiple peers or if more
+specific prefix of one peer shadows part of wider prefix of another peer.
+
+Wireguard silently moves a prefix to the last peer, which claims it, so such
+conflicts usually mean broken configuration.
+
+By default peers of different interfaces are not compared. Use --across for
+comparing them too, if output of wg show all dump is used.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("no conflicts of allowed IPs", args,
+				overlapResponse))
+		},
+	}
+)
+
+func init() {
+	f := overlapCmd.Flags()
+	f.BoolVar(&overlapAcross, "across", false,
+		"compare peers of different interfaces too")
+	f.BoolVar(&overlapIdenticalOnly, "identical-only", false,
+		"report identical prefixes only, ignoring shadowed ones")
+}
+
+func overlapResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	var overlaps []wg.Overlap
+	var identical, shadowed int
+	for _, o := range wg.Overlaps(dump.Peers, overlapAcross) {
+		switch o.Kind {
+		case wg.OverlapIdentical:
+			identical++
+		case wg.OverlapShadowed:
+			if overlapIdenticalOnly {
+				continue
+			}
+			shadowed++
+		}
+		overlaps = append(overlaps, o)
+	}
+
+	point := monitoringplugin.NewPerformanceDataPoint("conflicts", len(overlaps))
+	point.NewThresholds(0, 0, 0, 0).UseWarning(false, false)
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		point,
+		monitoringplugin.NewPerformanceDataPoint("identical", identical),
+		monitoringplugin.NewPerformanceDataPoint("shadowed", shadowed),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+
+	for i := range overlaps {
+		resp.UpdateStatus(monitoringplugin.CRITICAL, overlapString(&overlaps[i]))
+	}
+	return nil
+}
+
+func overlapString(o *wg.Overlap) string {
+	if o.Kind == wg.OverlapIdentical {
+		return fmt.Sprintf("identical %v: peer %v and peer %v", o.Prefix,
+			peerString(o.Peer), peerString(o.Other))
+	}
+	return fmt.Sprintf("shadowed %v of peer %v by %v of peer %v", o.Prefix,
+		peerString(o.Peer), o.OtherPrefix, peerString(o.Other))
+}
+
+// peerString returns name of peer with its interface, if it's known.
+func peerString(peer *wg.DumpPeer) string {
+	if peer.Interface == "" {
+		return peer.Name()
+	}
+	return peer.Name() + " on " + peer.Interface
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestOverlapResponse(t *testing.T) {
+	t.Cleanup(func() { overlapAcross, overlapIdenticalOnly = false, false })
+
+	dump := newRouteDump()
+	dump.Peers = append(dump.Peers, wg.DumpPeer{
+		PublicKey:  "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA=",
+		AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+		Interface:  "wg1",
+	})
+	for i := range dump.Peers[:2] {
+		dump.Peers[i].Interface = "wg0"
+	}
+
+	tests := []struct {
+		name          string
+		across        bool
+		identicalOnly bool
+		statusCode    int
+		output        []string
+	}{
+		{
+			name:       "default",
+			statusCode: monitoringplugin.CRITICAL,
+			output: []string{
+				"shadowed 192.168.0.0/16 of peer 10.0.0.2/32 on wg0 by 192.168.1.0/24 of peer 10.0.0.3/32 on wg0",
+				" 'conflicts'=1;;0;; 'identical'=0 'shadowed'=1",
+			},
+		},
+		{
+			name:       "across",
+			across:     true,
+			statusCode: monitoringplugin.CRITICAL,
+			output: []string{
+				"identical 10.0.0.2/32: peer 10.0.0.2/32 on wg0 and peer 10.0.0.2/32 on wg1",
+				" 'conflicts'=2;;0;; 'identical'=1 'shadowed'=1",
+			},
+		},
+		{
+			name:          "identical only",
+			identicalOnly: true,
+			statusCode:    monitoringplugin.OK,
+			output: []string{
+				"OK: test OK",
+				" 'conflicts'=0;;0;; 'identical'=0 'shadowed'=0",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overlapAcross, overlapIdenticalOnly = tt.across, tt.identicalOnly
+			resp := monitoringplugin.NewResponse("test OK")
+			require.NoError(t, overlapResponse(&dump, resp))
+			assert.Equal(t, tt.statusCode, resp.GetStatusCode())
+			for _, s := range tt.output {
+				assert.Contains(t, resp.GetInfo().RawOutput, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	peersStale     time.Duration
+	peersExclude   []string
+	peersList      bool
+	peersActiveMin struct{ Warn, Crit countThreshold }
+	peersStaleMax  struct{ Warn, Crit countThreshold }
+
+	peersCmd = cobra.Command{
+		Use:   "peers [--stale 5m] [--active-warn N[%]] [--stale-crit N[%]] [-x peer]... [wg show wg0 dump]",
+		Short: "check numbers of active, stale and never handshaked peers",
+		Long: `It classifies every peer by its latest handshake: active if the handshake is
+not older of --stale, stale if it's older and never if the peer never did a
+handshake at all.
+
+It outputs warning or critical status if number of active peers is lower of
+--active-warn or --active-crit, or if number of stale peers is greater of
+--stale-warn or --stale-crit. Thresholds can be given as number of peers or as
+percentage of all checked peers, like 10%. Never handshaked peers don't affect
+status, like road warriors, which never connected.
+
+With --list it outputs every stale peer.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("peers", args, peersResponse))
+		},
+	}
+)
+
+func init() {
+	f := peersCmd.Flags()
+	f.DurationVar(&peersStale, "stale", 5*time.Minute,
+		"peer is stale if its latest handshake is older of this")
+	f.StringArrayVarP(&peersExclude, "exclude", "x", nil,
+		"peers or @groups to exclude from check")
+	f.BoolVar(&peersList, "list", false, "output every stale peer")
+	f.Var(&peersActiveMin.Warn, "active-warn",
+		"warning if number of active peers is lower of this")
+	f.Var(&peersActiveMin.Crit, "active-crit",
+		"critical if number of active peers is lower of this")
+	f.Var(&peersStaleMax.Warn, "stale-warn",
+		"warning if number of stale peers is greater of this")
+	f.Var(&peersStaleMax.Crit, "stale-crit",
+		"critical if number of stale peers is greater of this")
+}
+
+// peersCounts is result of classification of peers.
+type peersCounts struct {
+	Total  int
+	Active int
+	Stale  []*wg.DumpPeer
+	Never  int
+}
+
+func (self *peersCounts) String() string {
+	return fmt.Sprintf("%d peers: %d active, %d stale, %d never", self.Total,
+		self.Active, len(self.Stale), self.Never)
+}
+
+func peersResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	counts := classifyPeers(dump, idents, time.Now())
+	resp.WithDefaultOkMessage(counts.String())
+
+	active := monitoringplugin.NewPerformanceDataPoint("active", counts.Active).
+		SetMax(counts.Total)
+	th := active.NewThresholds(peersActiveMin.Warn.Count(counts.Total), 0,
+		peersActiveMin.Crit.Count(counts.Total), 0)
+	th.UseWarning(peersActiveMin.Warn.IsSet(), false).
+		UseCritical(peersActiveMin.Crit.IsSet(), false)
+
+	stale := monitoringplugin.NewPerformanceDataPoint("stale", len(counts.Stale)).
+		SetMax(counts.Total)
+	th = stale.NewThresholds(0, peersStaleMax.Warn.Count(counts.Total),
+		0, peersStaleMax.Crit.Count(counts.Total))
+	th.UseWarning(peersStaleMax.Warn.IsSet(), peersStaleMax.Warn.IsSet()).
+		UseCritical(peersStaleMax.Crit.IsSet(), peersStaleMax.Crit.IsSet())
+
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		active,
+		stale,
+		monitoringplugin.NewPerformanceDataPoint("never", counts.Never).
+			SetMax(counts.Total),
+		monitoringplugin.NewPerformanceDataPoint("total", counts.Total),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+
+	if resp.GetStatusCode() != monitoringplugin.OK {
+		resp.UpdateStatus(resp.GetStatusCode(), counts.String())
+	}
+
+	if peersList {
+		for _, peer := range counts.Stale {
+			d := time.Since(peer.LatestHandshake).Truncate(time.Second)
+			err := outputPeerDetails(peer, resp.GetStatusCode(),
+				"latest handshake: "+d.String()+" ago", resp)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// classifyPeers counts active, stale and never handshaked peers, except
+// excluded by --exclude.
+func classifyPeers(dump *wg.Dump, idents *peerIdents, now time.Time,
+) (counts peersCounts) {
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		if idents.MatchAny(peer, peersExclude) {
+			continue
+		}
+
+		counts.Total++
+		switch {
+		case peer.LatestHandshake.IsZero():
+			counts.Never++
+		case now.Sub(peer.LatestHandshake) > peersStale:
+			counts.Stale = append(counts.Stale, peer)
+		default:
+			counts.Active++
+		}
+	}
+	return
+}
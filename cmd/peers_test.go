@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// usePeersDump returns dump with 2 active, 1 stale and 1 never handshaked
+// peers.
+func usePeersDump(t *testing.T) *wg.Dump {
+	t.Helper()
+	t.Cleanup(func() {
+		peersStale, peersExclude, peersList = 5*time.Minute, nil, false
+		peersActiveMin.Warn, peersActiveMin.Crit = countThreshold{}, countThreshold{}
+		peersStaleMax.Warn, peersStaleMax.Crit = countThreshold{}, countThreshold{}
+	})
+
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	now := time.Now()
+	dump.Peers[0].LatestHandshake = now.Add(-time.Minute)
+	dump.Peers[1].LatestHandshake = now.Add(-2 * time.Minute)
+	dump.Peers[2].LatestHandshake = now.Add(-time.Hour)
+	dump.Peers[3].LatestHandshake = time.Time{}
+	return dump
+}
+
+func TestPeersResponse(t *testing.T) {
+	dump := usePeersDump(t)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, peersResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Equal(t, "OK: 4 peers: 2 active, 1 stale, 1 never"+
+		" | 'active'=2;;;;4 'stale'=1;;;;4 'never'=1;;;;4 'total'=4", output)
+}
+
+func TestPeersResponse_thresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func()
+		wantStatus int
+		wantPerf   string
+	}{
+		{
+			name: "active ok",
+			setup: func() {
+				require.NoError(t, peersActiveMin.Warn.Set("2"))
+				require.NoError(t, peersActiveMin.Crit.Set("1"))
+			},
+			wantStatus: monitoringplugin.OK,
+			wantPerf:   "'active'=2;2:;1:;;4",
+		},
+		{
+			name: "active warning",
+			setup: func() {
+				require.NoError(t, peersActiveMin.Warn.Set("75%"))
+			},
+			wantStatus: monitoringplugin.WARNING,
+			wantPerf:   "'active'=2;3:;;;4",
+		},
+		{
+			name:       "stale critical",
+			setup:      func() { require.NoError(t, peersStaleMax.Crit.Set("0")) },
+			wantStatus: monitoringplugin.CRITICAL,
+			wantPerf:   "'stale'=1;;0;;4",
+		},
+		{
+			name: "stale percent",
+			setup: func() {
+				require.NoError(t, peersStaleMax.Warn.Set("25%"))
+			},
+			wantStatus: monitoringplugin.OK,
+			wantPerf:   "'stale'=1;1;;;4",
+		},
+		{
+			name:       "stale duration",
+			setup:      func() { peersStale = 90 * time.Second },
+			wantStatus: monitoringplugin.OK,
+			wantPerf:   "'active'=1;;;;4 'stale'=2;;;;4",
+		},
+		{
+			name: "exclude",
+			setup: func() {
+				peersExclude = []string{"10.0.0.4/32"}
+				require.NoError(t, peersStaleMax.Crit.Set("0"))
+			},
+			wantStatus: monitoringplugin.OK,
+			wantPerf:   "'stale'=0;;0;;3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := usePeersDump(t)
+			tt.setup()
+			resp := monitoringplugin.NewResponse("test OK")
+			require.NoError(t, peersResponse(dump, resp))
+			output := resp.GetInfo().RawOutput
+			t.Log(output)
+			assert.Equal(t, tt.wantStatus, resp.GetStatusCode())
+			assert.Contains(t, output, tt.wantPerf)
+		})
+	}
+}
+
+func TestPeersResponse_list(t *testing.T) {
+	dump := usePeersDump(t)
+	peersList = true
+	require.NoError(t, peersStaleMax.Warn.Set("0"))
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, peersResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "\n4 peers: 2 active, 1 stale, 1 never\n")
+	assert.Contains(t, output,
+		"\npeer: 10.0.0.4/32, latest handshake: 1h0m0s ago, endpoint: ")
+	assert.NotContains(t, output, "peer: 10.0.0.2/32")
+}
+
+func TestPeersResponse_errors(t *testing.T) {
+	dump := usePeersDump(t)
+	peerAliases = []string{"foo"}
+	t.Cleanup(func() { peerAliases = nil })
+	resp := monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, peersResponse(dump, resp), "invalid alias")
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// promFamily is a family of Prometheus metrics with one sample per peer.
+// Value returns false if peer has no sample.
+type promFamily struct {
+	Name  string
+	Type  string
+	Help  string
+	Value func(peer *wg.DumpPeer) (float64, bool)
+
+	// Label and LabelValue add extra label to every sample.
+	Label      string
+	LabelValue func(peer *wg.DumpPeer) string
+}
+
+var promPeerFamilies = [...]promFamily{
+	{
+		Name: "peer_latest_handshake_seconds",
+		Type: "gauge",
+		Help: "Unix time of latest handshake with peer, 0 if never.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			if peer.LatestHandshake.IsZero() {
+				return 0, true
+			}
+			return float64(peer.LatestHandshake.Unix()), true
+		},
+	},
+	{
+		Name: "peer_receive_bytes_total",
+		Type: "counter",
+		Help: "Bytes received from peer.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			return float64(peer.Rx), true
+		},
+	},
+	{
+		Name: "peer_transmit_bytes_total",
+		Type: "counter",
+		Help: "Bytes transmitted to peer.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			return float64(peer.Tx), true
+		},
+	},
+	{
+		Name: "peer_persistent_keepalive_seconds",
+		Type: "gauge",
+		Help: "Persistent keepalive interval of peer, 0 if off.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			return peer.Keepalive.Seconds(), true
+		},
+	},
+	{
+		Name: "peer_allowed_ips",
+		Type: "gauge",
+		Help: "Number of allowed IPs of peer.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			return float64(len(peer.AllowedIPs)), true
+		},
+	},
+	{
+		Name: "peer_endpoint_info",
+		Type: "gauge",
+		Help: "Endpoint of peer, given by endpoint label.",
+		Value: func(peer *wg.DumpPeer) (float64, bool) {
+			return 1, peer.HasEndpoint()
+		},
+		Label: "endpoint",
+		LabelValue: func(peer *wg.DumpPeer) string {
+			return peer.Endpoint.String()
+		},
+	},
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var (
+	promPrefix    string
+	promPeerLabel string
+
+	promPeerLabels = []string{"ip", "pubkey", "alias"}
+	promPrefixRe   = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+)
+
+func addPromFlags(f *pflag.FlagSet) {
+	f.StringVar(&promPrefix, "prefix", "wireguard", "prefix of metric names")
+	f.StringVar(&promPeerLabel, "peer-label", "ip",
+		"value of peer label: "+strings.Join(promPeerLabels, ", "))
+}
+
+// promMetrics writes wg.Dump in Prometheus text exposition format.
+type promMetrics struct {
+	Prefix string
+	// PeerLabel returns value of peer label.
+	PeerLabel func(peer *wg.DumpPeer) string
+}
+
+// newPromMetrics returns promMetrics configured by --prefix and --peer-label.
+// Peer label is the first allowed IP of peer, its public key or its alias. Peers
+// without alias are labeled by the first allowed IP.
+func newPromMetrics() (*promMetrics, error) {
+	if !promPrefixRe.MatchString(promPrefix) {
+		return nil, fmt.Errorf("invalid metric prefix: %q", promPrefix)
+	}
+	self := &promMetrics{Prefix: promPrefix}
+
+	switch promPeerLabel {
+	case "ip":
+		self.PeerLabel = (*wg.DumpPeer).Name
+	case "pubkey":
+		self.PeerLabel = func(peer *wg.DumpPeer) string { return peer.PublicKey }
+	case "alias":
+		idents, err := newPeerIdents()
+		if err != nil {
+			return nil, err
+		}
+		self.PeerLabel = func(peer *wg.DumpPeer) string {
+			if alias := idents.Alias(peer); alias != "" {
+				return alias
+			}
+			return peer.Name()
+		}
+	default:
+		return nil, fmt.Errorf("unknown peer label: %s", promPeerLabel)
+	}
+	return self, nil
+}
+
+// Write writes metrics of dump into w. If dump is nil, it writes up metric
+// with 0 value only.
+func (self *promMetrics) Write(w io.Writer, dump *wg.Dump) error {
+	var b strings.Builder
+	self.header(&b, "up", "gauge", "Whether wireguard dump was read successfully.")
+	if dump == nil {
+		self.sample(&b, "up", nil, 0)
+	} else {
+		self.sample(&b, "up", nil, 1)
+		self.dump(&b, dump)
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	return nil
+}
+
+func (self *promMetrics) dump(b *strings.Builder, dump *wg.Dump) {
+	// Peers of single interface dump, like wg show wg0 dump, don't know name of
+	// their interface, so they have no per-interface series.
+	ifacePeers := make(map[string]int)
+	for i := range dump.Peers {
+		if name := dump.Peers[i].Interface; name != "" {
+			ifacePeers[name]++
+		}
+	}
+
+	if len(ifacePeers) != 0 {
+		self.header(b, "interface_peers", "gauge", "Number of peers of interface.")
+		for _, name := range slices.Sorted(maps.Keys(ifacePeers)) {
+			self.sample(b, "interface_peers", []string{"interface", name},
+				float64(ifacePeers[name]))
+		}
+	}
+
+	for i := range promPeerFamilies {
+		f := &promPeerFamilies[i]
+		self.header(b, f.Name, f.Type, f.Help)
+		for j := range dump.Peers {
+			peer := &dump.Peers[j]
+			if v, ok := f.Value(peer); ok {
+				labels := self.peerLabels(peer)
+				if f.Label != "" {
+					labels = append(labels, f.Label, f.LabelValue(peer))
+				}
+				self.sample(b, f.Name, labels, v)
+			}
+		}
+	}
+}
+
+func (self *promMetrics) peerLabels(peer *wg.DumpPeer) []string {
+	labels := make([]string, 0, 8)
+	if peer.Interface != "" {
+		labels = append(labels, "interface", peer.Interface)
+	}
+	return append(labels, "peer", self.PeerLabel(peer),
+		"public_key", peer.PublicKey)
+}
+
+func (self *promMetrics) header(b *strings.Builder, name, typ, help string) {
+	name = self.Prefix + "_" + name
+	b.WriteString("# HELP " + name + " " + help + "\n")
+	b.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample writes single sample of metric. Labels are pairs of label name and
+// its value.
+func (self *promMetrics) sample(b *strings.Builder, name string,
+	labels []string, v float64,
+) {
+	b.WriteString(self.Prefix + "_" + name)
+	if len(labels) != 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i] + `="` + promLabelEscaper.Replace(labels[i+1]) +
+				`"`)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteString(" " + strconv.FormatFloat(v, 'f', -1, 64) + "\n")
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestPromMetrics_Write(t *testing.T) {
+	dump := wg.Dump{
+		Peers: []wg.DumpPeer{
+			{
+				PublicKey:       "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+				Endpoint:        netip.MustParseAddrPort("10.0.0.1:54321"),
+				AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+				LatestHandshake: time.Unix(1709565849, 0),
+				Rx:              293787123,
+				Tx:              2098018008,
+				Keepalive:       15 * time.Second,
+				Interface:       "wg0",
+			},
+			{
+				PublicKey: "C\"CC",
+				Rx:        10672758695,
+				Interface: "wg1",
+			},
+		},
+	}
+
+	var b strings.Builder
+	require.NoError(t, usePromMetrics(t).Write(&b, &dump))
+	t.Log(b.String())
+	assert.Equal(t, `# HELP wireguard_up Whether wireguard dump was read successfully.
+# TYPE wireguard_up gauge
+wireguard_up 1
+# HELP wireguard_interface_peers Number of peers of interface.
+# TYPE wireguard_interface_peers gauge
+wireguard_interface_peers{interface="wg0"} 1
+wireguard_interface_peers{interface="wg1"} 1
+# HELP wireguard_peer_latest_handshake_seconds Unix time of latest handshake with peer, 0 if never.
+# TYPE wireguard_peer_latest_handshake_seconds gauge
+wireguard_peer_latest_handshake_seconds{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="} 1709565849
+wireguard_peer_latest_handshake_seconds{interface="wg1",peer="C\"CC",public_key="C\"CC"} 0
+# HELP wireguard_peer_receive_bytes_total Bytes received from peer.
+# TYPE wireguard_peer_receive_bytes_total counter
+wireguard_peer_receive_bytes_total{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="} 293787123
+wireguard_peer_receive_bytes_total{interface="wg1",peer="C\"CC",public_key="C\"CC"} 10672758695
+# HELP wireguard_peer_transmit_bytes_total Bytes transmitted to peer.
+# TYPE wireguard_peer_transmit_bytes_total counter
+wireguard_peer_transmit_bytes_total{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="} 2098018008
+wireguard_peer_transmit_bytes_total{interface="wg1",peer="C\"CC",public_key="C\"CC"} 0
+# HELP wireguard_peer_persistent_keepalive_seconds Persistent keepalive interval of peer, 0 if off.
+# TYPE wireguard_peer_persistent_keepalive_seconds gauge
+wireguard_peer_persistent_keepalive_seconds{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="} 15
+wireguard_peer_persistent_keepalive_seconds{interface="wg1",peer="C\"CC",public_key="C\"CC"} 0
+# HELP wireguard_peer_allowed_ips Number of allowed IPs of peer.
+# TYPE wireguard_peer_allowed_ips gauge
+wireguard_peer_allowed_ips{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="} 1
+wireguard_peer_allowed_ips{interface="wg1",peer="C\"CC",public_key="C\"CC"} 0
+# HELP wireguard_peer_endpoint_info Endpoint of peer, given by endpoint label.
+# TYPE wireguard_peer_endpoint_info gauge
+wireguard_peer_endpoint_info{interface="wg0",peer="10.0.0.2/32",public_key="BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",endpoint="10.0.0.1:54321"} 1
+`, b.String())
+}
+
+func TestPromMetrics_Write_down(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, usePromMetrics(t).Write(&b, nil))
+	assert.Equal(t, `# HELP wireguard_up Whether wireguard dump was read successfully.
+# TYPE wireguard_up gauge
+wireguard_up 0
+`, b.String())
+}
+
+func TestPromMetrics_Write_noInterface(t *testing.T) {
+	dump := wg.Dump{Peers: []wg.DumpPeer{{PublicKey: "A"}}}
+	var b strings.Builder
+	require.NoError(t, usePromMetrics(t).Write(&b, &dump))
+	assert.NotContains(t, b.String(), "wireguard_interface_peers")
+	assert.Contains(t, b.String(),
+		"\nwireguard_peer_receive_bytes_total{peer=\"A\",public_key=\"A\"} 0\n")
+}
+
+func usePromMetrics(t *testing.T) *promMetrics {
+	t.Helper()
+	prom, err := newPromMetrics()
+	require.NoError(t, err)
+	return prom
+}
+
+func TestNewPromMetrics(t *testing.T) {
+	t.Cleanup(func() { promPrefix, promPeerLabel = "wireguard", "ip" })
+	usePeerIdents(t, []string{"router=10.0.0.2/32"}, nil)
+	dump := wg.Dump{
+		Peers: []wg.DumpPeer{
+			{
+				PublicKey:  "A",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+			},
+			{
+				PublicKey:  "B",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32")},
+			},
+		},
+	}
+
+	tests := []struct {
+		label string
+		want  []string
+	}{
+		{label: "ip", want: []string{`peer="10.0.0.2/32"`, `peer="10.0.0.3/32"`}},
+		{label: "pubkey", want: []string{`peer="A"`, `peer="B"`}},
+		{label: "alias", want: []string{`peer="router"`, `peer="10.0.0.3/32"`}},
+	}
+
+	promPrefix = "wg"
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			promPeerLabel = tt.label
+			var b strings.Builder
+			require.NoError(t, usePromMetrics(t).Write(&b, &dump))
+			t.Log(b.String())
+			for _, s := range tt.want {
+				assert.Contains(t, b.String(), "\nwg_peer_allowed_ips{"+s)
+			}
+		})
+	}
+
+	promPeerLabel = "foobar"
+	_, err := newPromMetrics()
+	require.ErrorContains(t, err, "unknown peer label: foobar")
+
+	promPeerLabel, promPrefix = "ip", "1wg"
+	_, err = newPromMetrics()
+	require.ErrorContains(t, err, "invalid metric prefix")
+
+	promPeerLabel, promPrefix, peerAliases = "alias", "wg", []string{"foobar"}
+	_, err = newPromMetrics()
+	require.ErrorContains(t, err, "invalid alias")
+}
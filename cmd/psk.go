@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	pskExempt []string
+
+	pskCmd = cobra.Command{
+		Use:   "psk [-x peer]... [wg show wg0 dump]",
+		Short: "check every peer has its own preshared key",
+		Long: `It outputs critical status if some peer has no preshared key or if the same
+preshared key is used by multiple peers, on the same interface or across
+interfaces with -a.
+
+Peers or @groups, given by -x, don't require preshared key, but their
+preshared keys are still checked for reuse.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("preshared keys", args, pskResponse))
+		},
+	}
+)
+
+func init() {
+	f := pskCmd.Flags()
+	f.StringArrayVarP(&pskExempt, "exempt", "x", nil,
+		"peers or @groups, which don't require preshared key")
+}
+
+func pskResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	var missing []*wg.DumpPeer
+	var exempt int
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		switch {
+		case peer.PresharedKey != "":
+		case idents.MatchAny(peer, pskExempt):
+			exempt++
+		default:
+			missing = append(missing, peer)
+		}
+	}
+
+	reused := reusedPresharedKeys(dump)
+	var reusedPeers int
+	for _, peers := range reused {
+		reusedPeers += len(peers)
+	}
+
+	total := len(dump.Peers)
+	resp.WithDefaultOkMessage(fmt.Sprintf(
+		"%d peers: %d with preshared key, %d exempt", total,
+		total-len(missing)-exempt, exempt))
+	if err := pskPerfData(total, len(missing), reusedPeers, resp); err != nil {
+		return err
+	}
+
+	for _, peer := range missing {
+		resp.UpdateStatus(monitoringplugin.CRITICAL,
+			"no preshared key: "+pskPeerString(peer, idents))
+	}
+
+	for _, peers := range reused {
+		names := make([]string, len(peers))
+		for i, peer := range peers {
+			names[i] = pskPeerString(peer, idents)
+		}
+		resp.UpdateStatus(monitoringplugin.CRITICAL,
+			"same preshared key: "+strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// reusedPresharedKeys returns groups of peers with the same preshared key, in
+// order of the first peer of every group.
+func reusedPresharedKeys(dump *wg.Dump) [][]*wg.DumpPeer {
+	var keys []string
+	peersByKey := make(map[string][]*wg.DumpPeer)
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		if peer.PresharedKey == "" {
+			continue
+		}
+		peers, ok := peersByKey[peer.PresharedKey]
+		if !ok {
+			keys = append(keys, peer.PresharedKey)
+		}
+		peersByKey[peer.PresharedKey] = append(peers, peer)
+	}
+
+	var reused [][]*wg.DumpPeer
+	for _, key := range keys {
+		if peers := peersByKey[key]; len(peers) > 1 {
+			reused = append(reused, peers)
+		}
+	}
+	return reused
+}
+
+func pskPerfData(total, missing, reused int, resp *monitoringplugin.Response,
+) error {
+	missingPoint := monitoringplugin.NewPerformanceDataPoint("missing", missing).
+		SetMax(total)
+	missingPoint.NewThresholds(0, 0, 0, 0).UseWarning(false, false)
+	reusedPoint := monitoringplugin.NewPerformanceDataPoint("reused", reused).
+		SetMax(total)
+	reusedPoint.NewThresholds(0, 0, 0, 0).UseWarning(false, false)
+
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		missingPoint,
+		reusedPoint,
+		monitoringplugin.NewPerformanceDataPoint("total", total),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
+
+// pskPeerString returns name of peer with its alias, if any.
+func pskPeerString(peer *wg.DumpPeer, idents *peerIdents) string {
+	if alias := idents.Alias(peer); alias != "" {
+		return alias + " (" + peerString(peer) + ")"
+	}
+	return peerString(peer)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPSK1 = "PPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPPA="
+	testPSK2 = "QQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQA="
+)
+
+func usePSKExempt(t *testing.T, exempt []string) {
+	t.Helper()
+	pskExempt = exempt
+	t.Cleanup(func() { pskExempt = nil })
+}
+
+func TestPSKResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	for i := range dump.Peers {
+		dump.Peers[i].PresharedKey = testPSK1[:40] + string(rune('A'+i)) + "AA="
+	}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, pskResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: 4 peers: 4 with preshared key, 0 exempt | 'missing'=0;;0;;4 'reused'=0;;0;;4 'total'=4",
+		resp.GetInfo().RawOutput)
+}
+
+func TestPSKResponse_missing(t *testing.T) {
+	usePeerIdents(t, []string{"laptop=10.0.0.3/32"},
+		[]string{"roaming=10.0.0.4/32,10.0.0.5/32"})
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.Peers[0].PresharedKey = testPSK1
+	dump.Peers[3].PresharedKey = testPSK2
+	usePSKExempt(t, []string{"@roaming"})
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, pskResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "no preshared key: laptop (10.0.0.3/32)")
+	assert.NotContains(t, output, "10.0.0.4/32")
+	assert.Contains(t, output,
+		"'missing'=1;;0;;4 'reused'=0;;0;;4 'total'=4")
+}
+
+func TestPSKResponse_reused(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	dump.Peers[0].PresharedKey = testPSK1
+	dump.Peers[1].PresharedKey = testPSK2
+	dump.Peers[2].PresharedKey = testPSK1
+	dump.Peers[2].Interface = "wg1"
+	dump.Peers[3].PresharedKey = testPSK1
+	usePSKExempt(t, []string{"10.0.0.3/32"})
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, pskResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output,
+		"same preshared key: 10.0.0.2/32, 10.0.0.4/32 on wg1, 10.0.0.5/32")
+	assert.Contains(t, output, "'missing'=0;;0;;4 'reused'=3;;0;;4")
+}
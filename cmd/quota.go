@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+)
+
+var quotaPeriods = []string{"day", "week", "month"}
+
+// quotaState is traffic of peer, accumulated since Start of quota period.
+type quotaState struct {
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	Rx     uint64    `json:"rx"`
+	Tx     uint64    `json:"tx"`
+}
+
+// Used returns traffic accounted by quota in given direction: total, rx or tx.
+func (self *quotaState) Used(direction string) (uint64, error) {
+	switch direction {
+	case "total":
+		return self.Rx + self.Tx, nil
+	case "rx":
+		return self.Rx, nil
+	case "tx":
+		return self.Tx, nil
+	}
+	return 0, fmt.Errorf("unknown quota direction: %s", direction)
+}
+
+// AddQuota accumulates traffic since prev into quota of current period. Quota
+// starts from zero at the beginning of every period and traffic since prev is
+// accounted by the period of current state.
+func (self *peerState) AddQuota(prev *peerState, period string) error {
+	start, err := periodStart(period, self.Time)
+	if err != nil {
+		return err
+	}
+
+	q := quotaState{Period: period, Start: start}
+	if prev == nil {
+		self.Quota = &q
+		return nil
+	} else if prev.Quota != nil && prev.Quota.Period == period &&
+		prev.Quota.Start.Equal(start) {
+		q = *prev.Quota
+	}
+
+	rx, tx, _ := self.Delta(prev)
+	q.Rx += rx
+	q.Tx += tx
+	self.Quota = &q
+	return nil
+}
+
+// periodStart returns beginning of given period: day, week or month, which
+// includes t. Weeks start on Monday.
+func periodStart(period string, t time.Time) (time.Time, error) {
+	y, m, d := t.Date()
+	switch period {
+	case "day":
+	case "week":
+		d -= (int(t.Weekday()) + 6) % 7
+	case "month":
+		d = 1
+	default:
+		return time.Time{}, fmt.Errorf("unknown quota period: %s", period)
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+}
+
+func checkQuotaFlags() error {
+	if _, err := periodStart(transferQuotaPeriod, time.Now()); err != nil {
+		return err
+	} else if _, err := (&quotaState{}).Used(transferQuotaDirection); err != nil {
+		return err
+	} else if transferQuotaWarn > transferQuotaCrit {
+		return errors.New("quota warning greater of critical")
+	}
+	return nil
+}
+
+func transferQuotaOutput(q *quotaState, resp *monitoringplugin.Response) error {
+	used, err := q.Used(transferQuotaDirection)
+	if err != nil {
+		return err
+	}
+
+	quota := uint64(transferQuota)
+	warn := uint64(float64(quota) * transferQuotaWarn / 100)
+	crit := uint64(float64(quota) * transferQuotaCrit / 100)
+
+	point := monitoringplugin.NewPerformanceDataPoint("quota used", used).
+		SetUnit("b").SetMax(quota)
+	point.NewThresholds(0, warn, 0, crit)
+	points := [...]*monitoringplugin.PerformanceDataPoint[uint64]{
+		point,
+		monitoringplugin.NewPerformanceDataPoint("quota remaining",
+			quota-min(used, quota)).SetUnit("b"),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+
+	resp.UpdateStatus(resp.GetStatusCode(), fmt.Sprintf(
+		"quota: %s of %s used (%.1f%%) since %s", formatBytes(used),
+		formatBytes(quota), float64(used)*100/float64(quota),
+		q.Start.Format(time.DateOnly)))
+	return nil
+}
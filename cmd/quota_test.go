@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodStart(t *testing.T) {
+	// Thursday
+	now := time.Date(2024, time.February, 29, 13, 14, 15, 16, time.Local)
+
+	tests := []struct {
+		period string
+		want   time.Time
+	}{
+		{period: "day", want: time.Date(2024, time.February, 29, 0, 0, 0, 0,
+			time.Local)},
+		{period: "week", want: time.Date(2024, time.February, 26, 0, 0, 0, 0,
+			time.Local)},
+		{period: "month", want: time.Date(2024, time.February, 1, 0, 0, 0, 0,
+			time.Local)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period, func(t *testing.T) {
+			got, err := periodStart(tt.period, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	sunday := time.Date(2024, time.March, 3, 23, 0, 0, 0, time.Local)
+	got, err := periodStart("week", sunday)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.February, 26, 0, 0, 0, 0, time.Local),
+		got)
+
+	_, err = periodStart("year", now)
+	require.ErrorContains(t, err, "unknown quota period: year")
+}
+
+func TestPeerState_AddQuota(t *testing.T) {
+	now := time.Date(2024, time.March, 2, 10, 0, 0, 0, time.Local)
+	month := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local)
+
+	cur := &peerState{Time: now, Rx: 1000, Tx: 2000}
+	require.NoError(t, cur.AddQuota(nil, "month"))
+	assert.Equal(t, &quotaState{Period: "month", Start: month}, cur.Quota)
+
+	prev := cur
+	cur = &peerState{Time: now.Add(time.Hour), Rx: 1500, Tx: 2100}
+	require.NoError(t, cur.AddQuota(prev, "month"))
+	assert.Equal(t, &quotaState{Period: "month", Start: month, Rx: 500, Tx: 100},
+		cur.Quota)
+
+	prev = cur
+	cur = &peerState{Time: now.Add(2 * time.Hour), Rx: 100, Tx: 200}
+	require.NoError(t, cur.AddQuota(prev, "month"))
+	assert.Equal(t, &quotaState{Period: "month", Start: month, Rx: 600, Tx: 300},
+		cur.Quota)
+
+	prev = cur
+	cur = &peerState{Time: now.Add(3 * time.Hour), Rx: 200, Tx: 300}
+	require.NoError(t, cur.AddQuota(prev, "day"))
+	assert.Equal(t, &quotaState{
+		Period: "day",
+		Start:  time.Date(2024, time.March, 2, 0, 0, 0, 0, time.Local),
+		Rx:     100,
+		Tx:     100,
+	}, cur.Quota)
+
+	prev = cur
+	cur = &peerState{Time: now.Add(24 * time.Hour), Rx: 300, Tx: 400}
+	require.NoError(t, cur.AddQuota(prev, "day"))
+	assert.Equal(t, &quotaState{
+		Period: "day",
+		Start:  time.Date(2024, time.March, 3, 0, 0, 0, 0, time.Local),
+		Rx:     100,
+		Tx:     100,
+	}, cur.Quota)
+
+	require.Error(t, cur.AddQuota(prev, "year"))
+}
+
+func TestQuotaState_Used(t *testing.T) {
+	q := quotaState{Rx: 1, Tx: 2}
+	for direction, want := range map[string]uint64{"total": 3, "rx": 1, "tx": 2} {
+		used, err := q.Used(direction)
+		require.NoError(t, err)
+		assert.Equal(t, want, used, direction)
+	}
+	_, err := q.Used("both")
+	require.ErrorContains(t, err, "unknown quota direction: both")
+}
+
+func TestTransferResponse_quota(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+	peer.Rx, peer.Tx = 10<<30, 10<<30
+
+	transferQuota = 100 << 30
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "quota: 0B of 100GiB used (0.0%) since ")
+	assert.Contains(t, output,
+		" 'quota used'=0b;85899345920;107374182400;;107374182400")
+	assert.Contains(t, output, " 'quota remaining'=107374182400b")
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	st := state.Peer(peer)
+	require.NotNil(t, st)
+	require.NotNil(t, st.Quota)
+	st.Time = time.Now().Add(-time.Second)
+	st.Quota.Rx = 80 << 30
+	st.Rx -= 5 << 30
+	st.Tx -= 1 << 30
+	require.NoError(t, state.Save(name))
+
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "quota used is outside of WARNING threshold")
+	assert.Contains(t, output, "quota: 86GiB of 100GiB used (86.0%) since ")
+	assert.Contains(t, output, " 'quota remaining'=15032385536b")
+
+	transferQuotaDirection = "tx"
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput, "quota: 1GiB of 100GiB used")
+}
+
+func TestTransferResponse_quotaErrors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+	t.Cleanup(func() { transferQuota = 0 })
+
+	transferQuota = 1 << 30
+	resp := monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, transferResponse(dump, peer.Name(), resp),
+		"--quota requires --state")
+
+	useTransferState(t)
+	tests := []struct {
+		name    string
+		setup   func()
+		wantErr string
+	}{
+		{
+			name:    "period",
+			setup:   func() { transferQuotaPeriod = "year" },
+			wantErr: "unknown quota period: year",
+		},
+		{
+			name:    "direction",
+			setup:   func() { transferQuotaDirection = "both" },
+			wantErr: "unknown quota direction: both",
+		},
+		{
+			name:    "thresholds",
+			setup:   func() { transferQuotaWarn = 101 },
+			wantErr: "quota warning greater of critical",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transferQuota = 1 << 30
+			transferQuotaPeriod, transferQuotaDirection = "month", "total"
+			transferQuotaWarn, transferQuotaCrit = 80, 100
+			tt.setup()
+			resp := monitoringplugin.NewResponse("test OK")
+			require.ErrorContains(t, transferResponse(dump, peer.Name(), resp),
+				tt.wantErr)
+		})
+	}
+}
+
+func TestTransferResponse_quotaSharedState(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peers := dump.Peers[1:3]
+
+	transferQuota = 100 << 30
+	for i := range peers {
+		resp := monitoringplugin.NewResponse("test OK")
+		require.NoError(t, transferResponse(dump, peers[i].Name(), resp))
+	}
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	for i := range peers {
+		st := state.Peer(&peers[i])
+		require.NotNil(t, st, peers[i].Name())
+		st.Time = time.Now().Add(-time.Second)
+		st.Rx -= uint64(i+1) << 20
+	}
+	require.NoError(t, state.Save(name))
+
+	for i := range peers {
+		resp := monitoringplugin.NewResponse("test OK")
+		require.NoError(t, transferResponse(dump, peers[i].Name(), resp))
+	}
+
+	state, err = loadState(name)
+	require.NoError(t, err)
+	for i := range peers {
+		st := state.Peer(&peers[i])
+		require.NotNil(t, st, peers[i].Name())
+		require.NotNil(t, st.Quota, peers[i].Name())
+		assert.Equal(t, uint64(i+1)<<20, st.Quota.Rx, peers[i].Name())
+	}
+}
+
+func TestTransferResponse_quotaWithoutFlag(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+
+	transferQuota = 100 << 30
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	st := state.Peer(peer)
+	require.NotNil(t, st)
+	st.Time = time.Now().Add(-time.Second)
+	st.Rx -= 1 << 20
+	require.NoError(t, state.Save(name))
+
+	transferQuota = 0
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.NotContains(t, resp.GetInfo().RawOutput, "quota")
+
+	state, err = loadState(name)
+	require.NoError(t, err)
+	st = state.Peer(peer)
+	require.NotNil(t, st)
+	require.NotNil(t, st.Quota)
+	assert.Equal(t, uint64(1<<20), st.Quota.Rx)
+}
@@ -1,10 +1,9 @@
 package cmd
 
 import (
-	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/dsh2dsh/go-monitoringplugin/v2"
 	"github.com/spf13/cobra"
@@ -12,19 +11,68 @@ import (
 	"github.com/dsh2dsh/check_wg/wg"
 )
 
-var rootCmd = cobra.Command{
-	Use:   "check_wg",
-	Short: "Icinga2 health check of wireguard peers, using output of wg(8).",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		// Don't show usage on app errors.
-		// https://github.com/spf13/cobra/issues/340#issuecomment-378726225
-		cmd.SilenceUsage = true
-	},
-}
+var (
+	allInterfaces bool
+	interfaces    []string
+	uapiSockets   []string
+
+	peerAliases []string
+	peerGroups  []string
+
+	sourceName string
+	cacheFile  string
+	cacheTTL   time.Duration
+
+	rootCmd = cobra.Command{
+		Use:   "check_wg",
+		Short: "Icinga2 health check of wireguard peers, using output of wg(8).",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Don't show usage on app errors.
+			// https://github.com/spf13/cobra/issues/340#issuecomment-378726225
+			cmd.SilenceUsage = true
+			return checkOutputFormat()
+		},
+	}
+)
 
 func init() {
+	f := rootCmd.PersistentFlags()
+	f.BoolVarP(&allInterfaces, "all", "a", false,
+		"read output of wg show all dump")
+	f.StringArrayVarP(&interfaces, "interface", "i", nil,
+		"check only given interfaces of wg show all dump (implies --all)")
+	f.StringArrayVar(&uapiSockets, "uapi", nil,
+		"read interface from UAPI socket, like wg0 or /var/run/wireguard/wg0.sock")
+	f.StringVar(&sourceName, "source", "auto",
+		"where to read dump from: "+strings.Join(sourceNames(), ", "))
+	f.StringVar(&cacheFile, "cache-file", "",
+		"file with cached output of wg(8) for cache source")
+	f.DurationVar(&cacheTTL, "cache-ttl", time.Minute,
+		"how long cached output of wg(8) is valid")
+
+	f.StringArrayVar(&peerAliases, "alias", nil,
+		"alias of peer as NAME=PEER, where PEER is name, allowed IP or public key")
+	f.StringArrayVar(&peerGroups, "group", nil,
+		"group of peers as NAME=PEER[,PEER]..., referenced as @NAME")
+	f.StringVar(&outputFormat, "format", "text",
+		"output format of check result: "+strings.Join(outputFormats, ", "))
+
 	rootCmd.AddCommand(&handshakeCmd)
 	rootCmd.AddCommand(&transferCmd)
+	rootCmd.AddCommand(&routeCmd)
+	rootCmd.AddCommand(&overlapCmd)
+	rootCmd.AddCommand(&peersCmd)
+	rootCmd.AddCommand(&inventoryCmd)
+	rootCmd.AddCommand(&pskCmd)
+	rootCmd.AddCommand(&keepaliveCmd)
+	rootCmd.AddCommand(&sessionCmd)
+	rootCmd.AddCommand(&trafficCmd)
+	rootCmd.AddCommand(&endpointCmd)
+	rootCmd.AddCommand(&interfaceCmd)
+	rootCmd.AddCommand(&configAuditCmd)
+	rootCmd.AddCommand(&serveCmd)
+	rootCmd.AddCommand(&metricsCmd)
+	rootCmd.AddCommand(&dumpCmd)
 }
 
 func Execute(version string) {
@@ -46,58 +94,3 @@ func monitoringResponse(msgOk string, args []string,
 	resp.UpdateStatusOnError(err, monitoringplugin.UNKNOWN, "", true)
 	return resp
 }
-
-func NewWgDump(args []string) (dump wg.Dump, err error) {
-	err = withWgCmd(args, func(r io.Reader) error {
-		dump, err = wg.NewDump(r)
-		if err != nil {
-			if len(args) == 0 {
-				return fmt.Errorf("with input from stdin: %w", err)
-			}
-			return fmt.Errorf("with input from %v: %w", args, err)
-		}
-		return nil
-	})
-	return
-}
-
-func withWgCmd(args []string, fn func(r io.Reader) error) error {
-	r, cmd, err := startWgCmd(args)
-	if err != nil {
-		return err
-	}
-
-	if err := fn(r); err != nil {
-		return err
-	}
-
-	if cmd != nil {
-		if err := cmd.Wait(); err != nil {
-			return fmt.Errorf("wait for %v: %w", args, err)
-		}
-	}
-	return nil
-}
-
-func startWgCmd(args []string) (io.Reader, *exec.Cmd, error) {
-	if len(args) == 0 {
-		return os.Stdin, nil, nil
-	}
-
-	var cmdArgs []string
-	if len(args) > 1 {
-		cmdArgs = args[1:]
-	}
-
-	cmd := exec.Command(args[0], cmdArgs...)
-	cmd.Stderr = os.Stderr
-
-	stdout, err := cmd.StdoutPipe()
-	const errMsg = "exec %v: %w"
-	if err != nil {
-		return nil, nil, fmt.Errorf(errMsg, args, err)
-	} else if err := cmd.Start(); err != nil {
-		return nil, nil, fmt.Errorf(errMsg, args, err)
-	}
-	return stdout, cmd, nil
-}
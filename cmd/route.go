@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	routeExpect string
+
+	routeCmd = cobra.Command{
+		Use:   "route [-e peer] ADDR [wg show wg0 dump]",
+		Short: "check which peer routes given address",
+		Long: `It finds peer, which routes given address, using longest prefix match of
+allowed IPs, like wireguard does it.
+
+It outputs critical status if no peer routes given address or, if -e given, if
+it's routed by another peer.`,
+		Args: cobra.MinimumNArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			addr := args[0]
+			outputAndExit(monitoringResponse("route", args[1:],
+				func(dump *wg.Dump, resp *monitoringplugin.Response) error {
+					return routeResponse(dump, addr, resp)
+				}))
+		},
+	}
+)
+
+func init() {
+	f := routeCmd.Flags()
+	f.StringVarP(&routeExpect, "expect", "e", "",
+		"peer (name, public key or alias), which must route given address")
+}
+
+func routeResponse(dump *wg.Dump, s string, resp *monitoringplugin.Response,
+) error {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return fmt.Errorf("parse address %q: %w", s, err)
+	}
+
+	peer, prefix := dump.Route(addr)
+	if peer == nil {
+		resp.UpdateStatus(monitoringplugin.CRITICAL, "no peer routes "+s)
+		return nil
+	}
+	resp.WithDefaultOkMessage(s + " via " + prefix.String())
+
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	} else if routeExpect != "" && !idents.Match(peer, routeExpect) {
+		resp.UpdateStatus(monitoringplugin.CRITICAL,
+			s+" via "+prefix.String()+" of unexpected peer")
+		resp.UpdateStatus(monitoringplugin.CRITICAL, "expected peer: "+routeExpect)
+	}
+	return outputPeerEndpoint(peer, resp)
+}
+
+// findPeer returns peer, found by peerIdents.Find, or error if no such peer.
+func findPeer(dump *wg.Dump, idents *peerIdents, name string,
+) (*wg.DumpPeer, netip.Prefix, error) {
+	peer, prefix := idents.Find(dump, name)
+	if peer == nil {
+		return nil, netip.Prefix{}, fmt.Errorf("peer not found: %s", name)
+	}
+	return peer, prefix, nil
+}
+
+func outputRoute(name string, prefix netip.Prefix,
+	resp *monitoringplugin.Response,
+) {
+	if prefix.IsValid() {
+		resp.UpdateStatus(resp.GetStatusCode(),
+			"route: "+name+" via "+prefix.String())
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func newRouteDump() wg.Dump {
+	return wg.Dump{Peers: []wg.DumpPeer{
+		{
+			PublicKey: "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("192.168.0.0/16"),
+			},
+		},
+		{
+			PublicKey: "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.3/32"),
+				netip.MustParsePrefix("192.168.1.0/24"),
+			},
+		},
+	}}
+}
+
+func TestRouteResponse(t *testing.T) {
+	t.Cleanup(func() { routeExpect = "" })
+	dump := newRouteDump()
+
+	tests := []struct {
+		name       string
+		addr       string
+		expect     string
+		statusCode int
+		output     []string
+	}{
+		{
+			name:       "host",
+			addr:       "10.0.0.2",
+			statusCode: monitoringplugin.OK,
+			output:     []string{"10.0.0.2 via 10.0.0.2/32", "peer: 10.0.0.2/32"},
+		},
+		{
+			name:       "longest prefix",
+			addr:       "192.168.1.10",
+			expect:     "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+			statusCode: monitoringplugin.OK,
+			output: []string{
+				"192.168.1.10 via 192.168.1.0/24",
+				"peer: 10.0.0.3/32",
+			},
+		},
+		{
+			name:       "unexpected peer",
+			addr:       "192.168.2.10",
+			expect:     "10.0.0.3/32",
+			statusCode: monitoringplugin.CRITICAL,
+			output: []string{
+				"192.168.2.10 via 192.168.0.0/16 of unexpected peer",
+				"expected peer: 10.0.0.3/32",
+			},
+		},
+		{
+			name:       "not routed",
+			addr:       "10.0.0.4",
+			statusCode: monitoringplugin.CRITICAL,
+			output:     []string{"no peer routes 10.0.0.4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeExpect = tt.expect
+			resp := monitoringplugin.NewResponse("test OK")
+			require.NoError(t, routeResponse(&dump, tt.addr, resp))
+			assert.Equal(t, tt.statusCode, resp.GetStatusCode())
+			for _, s := range tt.output {
+				assert.Contains(t, resp.GetInfo().RawOutput, s)
+			}
+		})
+	}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, routeResponse(&dump, "foobar", resp),
+		`parse address "foobar"`)
+}
+
+func TestFindPeer(t *testing.T) {
+	idents := usePeerIdents(t, nil, nil)
+	dump := newRouteDump()
+
+	tests := []struct {
+		name   string
+		peer   int
+		prefix string
+	}{
+		{name: "10.0.0.2/32"},
+		{name: "10.0.0.3", peer: 1},
+		{name: "192.168.1.0/24", peer: 1},
+		{name: "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=", peer: 1},
+		{name: "10.0.0.3/24", peer: 1, prefix: "10.0.0.3/32"},
+		{name: "192.168.1.1", peer: 1, prefix: "192.168.1.0/24"},
+		{name: "192.168.2.1/32", prefix: "192.168.0.0/16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer, prefix, err := findPeer(&dump, idents, tt.name)
+			require.NoError(t, err)
+			assert.Same(t, &dump.Peers[tt.peer], peer)
+			if tt.prefix == "" {
+				assert.False(t, prefix.IsValid())
+			} else {
+				assert.Equal(t, netip.MustParsePrefix(tt.prefix), prefix)
+			}
+		})
+	}
+
+	for _, name := range []string{"foobar", "10.0.0.4", "10.0.0.4/32"} {
+		_, _, err := findPeer(&dump, idents, name)
+		require.ErrorContains(t, err, "peer not found: "+name)
+	}
+}
+
+func TestFindPeer_alias(t *testing.T) {
+	dump := newRouteDump()
+	idents := usePeerIdents(t, []string{
+		"r=" + dump.Peers[1].PublicKey,
+		"lan=192.168.1.1",
+		"10.0.0.3=10.0.0.2/32",
+		"lost=10.0.0.4",
+	}, nil)
+
+	tests := []struct {
+		name   string
+		peer   int
+		prefix string
+	}{
+		{name: "r", peer: 1},
+		{name: "lan", peer: 1, prefix: "192.168.1.0/24"},
+		{name: "10.0.0.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer, prefix, err := findPeer(&dump, idents, tt.name)
+			require.NoError(t, err)
+			assert.Same(t, &dump.Peers[tt.peer], peer)
+			if tt.prefix == "" {
+				assert.False(t, prefix.IsValid())
+			} else {
+				assert.Equal(t, netip.MustParsePrefix(tt.prefix), prefix)
+			}
+		})
+	}
+
+	_, _, err := findPeer(&dump, idents, "lost")
+	require.ErrorContains(t, err, "peer not found: lost")
+}
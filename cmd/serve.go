@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveListen   string
+	serveInterval time.Duration
+
+	serveCmd = cobra.Command{
+		Use:   "serve [--listen :9586] [--interval 15s] [wg show all dump]",
+		Short: "expose Prometheus metrics over HTTP",
+		Long: `It runs HTTP server, which exposes metrics of interfaces and peers on
+/metrics in Prometheus format.
+
+Every --interval it reads wireguard dump, like any other command, using given
+wg(8) command or UAPI sockets, and updates the metrics. If the dump can't be
+read, only up metric with 0 value exposed, until next successful
+read. It can't read the dump from stdin.`,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt,
+				syscall.SIGTERM)
+			defer stop()
+			return serveMetrics(ctx, args)
+		},
+	}
+)
+
+func init() {
+	f := serveCmd.Flags()
+	f.StringVar(&serveListen, "listen", ":9586", "listen on this address")
+	f.DurationVar(&serveInterval, "interval", 15*time.Second,
+		"how often to read wireguard dump")
+	addPromFlags(f)
+}
+
+func serveMetrics(ctx context.Context, args []string) error {
+	if serveInterval <= 0 {
+		return fmt.Errorf("invalid interval: %v", serveInterval)
+	} else if err := checkServeSource(args); err != nil {
+		return err
+	}
+
+	prom, err := newPromMetrics()
+	if err != nil {
+		return err
+	}
+
+	metrics := newMetricsHandler(args, prom)
+	metrics.Update()
+	go metrics.Run(ctx, serveInterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics)
+	srv := http.Server{
+		Addr:              serveListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("shutdown http server", slog.Any("error", err))
+		}
+	}()
+
+	slog.Info("listen", slog.String("addr", serveListen))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("listen on %q: %w", serveListen, err)
+	}
+	return nil
+}
+
+// checkServeSource returns error, if dump would be read from stdin, because it
+// can be read only once, but serve reads dump every --interval.
+func checkServeSource(args []string) error {
+	switch {
+	case sourceName == "stdin":
+	case sourceName == "auto" && len(args) == 0 && len(uapiSockets) == 0:
+	default:
+		return nil
+	}
+	return errors.New(
+		"serve can't read dump from stdin, give wg(8) command or --uapi")
+}
+
+// metricsHandler serves metrics of wireguard dump, updated by Update.
+type metricsHandler struct {
+	args    []string
+	metrics *promMetrics
+
+	mu sync.RWMutex
+	b  []byte
+}
+
+func newMetricsHandler(args []string, metrics *promMetrics) *metricsHandler {
+	return &metricsHandler{args: args, metrics: metrics}
+}
+
+// Run updates metrics every interval, until ctx canceled.
+func (self *metricsHandler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			self.Update()
+		}
+	}
+}
+
+// Update reads wireguard dump and renders its metrics.
+func (self *metricsHandler) Update() {
+	var b bytes.Buffer
+	dump, err := NewWgDump(self.args)
+	if err != nil {
+		slog.Error("read wireguard dump", slog.Any("error", err))
+		err = self.metrics.Write(&b, nil)
+	} else {
+		err = self.metrics.Write(&b, &dump)
+	}
+
+	if err != nil {
+		slog.Error("render metrics", slog.Any("error", err))
+		return
+	}
+
+	self.mu.Lock()
+	self.b = b.Bytes()
+	self.mu.Unlock()
+}
+
+func (self *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.RLock()
+	b := self.b
+	self.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := w.Write(b); err != nil {
+		slog.Error("write metrics", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	metrics := newMetricsHandler(nil, usePromMetrics(t))
+	metrics.Update()
+
+	srv := httptest.NewServer(metrics)
+	t.Cleanup(srv.Close)
+
+	body := getMetrics(t, srv.URL)
+	assert.Contains(t, body, "\nwireguard_up 1\n")
+	assert.NotContains(t, body, "wireguard_interface_peers")
+	assert.Contains(t, body,
+		"\nwireguard_peer_receive_bytes_total{peer=\"10.0.0.3/32\",public_key=\""+
+			dump.Peers[1].PublicKey+"\"} 984267560\n")
+
+	dump.Peers[1].Rx = 984267561
+	metrics.Update()
+	assert.Contains(t, getMetrics(t, srv.URL),
+		"\nwireguard_peer_receive_bytes_total{peer=\"10.0.0.3/32\",public_key=\""+
+			dump.Peers[1].PublicKey+"\"} 984267561\n")
+}
+
+func TestMetricsHandler_down(t *testing.T) {
+	useSource(t, SourceFunc(func(args []string) (wg.Dump, error) {
+		return wg.Dump{}, errors.New("test error")
+	}))
+	metrics := newMetricsHandler(nil, usePromMetrics(t))
+	metrics.Update()
+
+	srv := httptest.NewServer(metrics)
+	t.Cleanup(srv.Close)
+	body := getMetrics(t, srv.URL)
+	assert.Contains(t, body, "\nwireguard_up 0\n")
+	assert.NotContains(t, body, "wireguard_peer_")
+}
+
+func TestMetricsHandler_Run(t *testing.T) {
+	var calls int
+	useSource(t, SourceFunc(func(args []string) (wg.Dump, error) {
+		calls++
+		return wg.Dump{}, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	newMetricsHandler(nil, usePromMetrics(t)).Run(ctx, 10*time.Millisecond)
+	assert.Positive(t, calls)
+}
+
+func TestServeMetrics_errors(t *testing.T) {
+	t.Cleanup(func() {
+		serveListen, serveInterval = ":9586", 15*time.Second
+	})
+	useSource(t, SourceFunc(func(args []string) (wg.Dump, error) {
+		return wg.Dump{}, nil
+	}))
+
+	serveInterval = 0
+	require.ErrorContains(t, serveMetrics(t.Context(), nil), "invalid interval")
+
+	serveInterval, serveListen = time.Second, "foobar"
+	require.ErrorContains(t, serveMetrics(t.Context(), nil),
+		`listen on "foobar"`)
+
+	sourceName = "auto"
+	require.ErrorContains(t, serveMetrics(t.Context(), nil),
+		"serve can't read dump from stdin")
+
+	sourceName = "stdin"
+	require.ErrorContains(t, serveMetrics(t.Context(), nil),
+		"serve can't read dump from stdin")
+}
+
+func getMetrics(t *testing.T, url string) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8",
+		resp.Header.Get("Content-Type"))
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// rejectAfterTime is REJECT_AFTER_TIME of wireguard protocol, after which a
+// session can't be used anymore without new handshake.
+const rejectAfterTime = 180 * time.Second
+
+var (
+	sessionState       string
+	sessionMinBytes    byteSize
+	sessionRejectAfter time.Duration
+	sessionExclude     []string
+
+	sessionCmd = cobra.Command{
+		Use:   "session --state FILE [--min-bytes SIZE] [-x peer]... [wg show wg0 dump]",
+		Short: "check for traffic without working session",
+		Long: `It saves counters of peers into given state file and compares them with
+counters of previous run, looking for broken sessions:
+
+  * critical, if a peer transferred traffic, but its session expired before
+    previous run, because latest handshake is older of --reject-after, which is
+    180s by wireguard protocol, or it never did a handshake at all, so traffic
+    was attempted with expired session;
+  * warning, if traffic grows in one direction only, like tx grows, but rx
+    doesn't, which usually means one side is sending into a black hole.
+
+Peers, which transferred less of --min-bytes in both directions since previous
+run, are idle and ignored. The first run only creates the state.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("sessions", args, sessionResponse))
+		},
+	}
+)
+
+func init() {
+	f := sessionCmd.Flags()
+	f.StringVar(&sessionState, "state", "",
+		"file with counters of previous run")
+	f.Var(&sessionMinBytes, "min-bytes",
+		"ignore peers, which transferred less of this since previous run")
+	f.DurationVar(&sessionRejectAfter, "reject-after", rejectAfterTime,
+		"session is expired if latest handshake is older of this")
+	f.StringArrayVarP(&sessionExclude, "exclude", "x", nil,
+		"peers or @groups to exclude from check")
+}
+
+func sessionResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	if sessionState == "" {
+		return errors.New("--state is required")
+	}
+
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	var checked, expired, oneWay int
+	hasPrev, err := updatePeersState(sessionState, "session", dump,
+		func(peer *wg.DumpPeer, prev, cur *peerState) (bool, error) {
+			if idents.MatchAny(peer, sessionExclude) {
+				return false, nil
+			}
+			checked++
+
+			status, msg := sessionBroken(peer, prev, cur)
+			switch status {
+			case monitoringplugin.OK:
+				return true, nil
+			case monitoringplugin.CRITICAL:
+				expired++
+			default:
+				oneWay++
+			}
+			return true, outputPeerDetails(peer, status, msg, resp)
+		})
+
+	if err != nil {
+		return err
+	} else if !hasPrev {
+		resp.WithDefaultOkMessage(
+			"no previous state, sessions will be checked by next run")
+	} else {
+		resp.WithDefaultOkMessage(fmt.Sprintf(
+			"no broken sessions of %d peers", checked))
+	}
+	return sessionPerfData(expired, oneWay, resp)
+}
+
+// sessionBroken returns status and description of broken session of peer or OK
+// and empty string.
+func sessionBroken(peer *wg.DumpPeer, prev, cur *peerState) (int, string) {
+	rx, tx, _ := cur.Delta(prev)
+	minBytes := uint64(sessionMinBytes)
+	if rx < minBytes && tx < minBytes || rx == 0 && tx == 0 {
+		return monitoringplugin.OK, ""
+	}
+
+	var direction string
+	switch {
+	case rx == 0:
+		direction = fmt.Sprintf("tx %s without rx", formatBytes(tx))
+	case tx == 0:
+		direction = fmt.Sprintf("rx %s without tx", formatBytes(rx))
+	}
+
+	// Session expired before previous run, so all the traffic since previous
+	// run was attempted without session.
+	if peer.LatestHandshake.IsZero() {
+		return monitoringplugin.CRITICAL, sessionMessage(direction,
+			"traffic without handshake")
+	} else if peer.LatestHandshake.Add(sessionRejectAfter).Before(prev.Time) {
+		d := cur.Time.Sub(peer.LatestHandshake).Truncate(time.Second)
+		return monitoringplugin.CRITICAL, sessionMessage(direction,
+			"traffic with expired session, latest handshake: "+d.String()+" ago")
+	} else if direction != "" {
+		return monitoringplugin.WARNING, direction
+	}
+	return monitoringplugin.OK, ""
+}
+
+func sessionMessage(direction, msg string) string {
+	if direction == "" {
+		return msg
+	}
+	return direction + ", " + msg
+}
+
+func sessionPerfData(expired, oneWay int, resp *monitoringplugin.Response,
+) error {
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		monitoringplugin.NewPerformanceDataPoint("expired", expired),
+		monitoringplugin.NewPerformanceDataPoint("one way", oneWay),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
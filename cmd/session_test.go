@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func useSessionState(t *testing.T) string {
+	t.Helper()
+	sessionState = filepath.Join(t.TempDir(), "session.json")
+	t.Cleanup(func() {
+		sessionState, sessionMinBytes, sessionExclude = "", 0, nil
+		sessionRejectAfter = rejectAfterTime
+	})
+	return sessionState
+}
+
+// saveSessionState saves counters of every peer, decreased by given bytes, 5
+// minutes ago.
+func saveSessionState(t *testing.T, dump *wg.Dump, rx, tx uint64) {
+	t.Helper()
+	state, err := loadState(sessionState)
+	require.NoError(t, err)
+
+	prevTime := time.Now().Add(-5 * time.Minute)
+	for i := range dump.Peers {
+		peer := &dump.Peers[i]
+		st := newPeerState(peer, prevTime)
+		st.Rx, st.Tx = peer.Rx-rx, peer.Tx-tx
+		state.SetPeer(peer, st)
+	}
+	require.NoError(t, state.Save(sessionState))
+}
+
+func TestSessionResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useSessionState(t)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, sessionResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no previous state, sessions will be checked by next run | 'expired'=0 'one way'=0",
+		resp.GetInfo().RawOutput)
+
+	// latest handshakes of test dump are expired, but peers are idle.
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, sessionResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no broken sessions of 4 peers | 'expired'=0 'one way'=0",
+		resp.GetInfo().RawOutput)
+
+	for i := range dump.Peers {
+		dump.Peers[i].LatestHandshake = time.Now().Add(-time.Minute)
+	}
+	saveSessionState(t, dump, 1024, 2048)
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, sessionResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+}
+
+func TestSessionResponse_excluded(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useSessionState(t)
+
+	state, err := loadState(sessionState)
+	require.NoError(t, err)
+	peer := &dump.Peers[0]
+	state.SetPeer(peer, newPeerState(peer, time.Now().Add(-time.Minute)))
+	require.NoError(t, state.Save(sessionState))
+
+	sessionExclude = []string{peer.Name()}
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, sessionResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no previous state, sessions will be checked by next run | 'expired'=0 'one way'=0",
+		resp.GetInfo().RawOutput)
+}
+
+func TestSessionResponse_broken(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useSessionState(t)
+	for i := range dump.Peers {
+		dump.Peers[i].LatestHandshake = time.Now().Add(-time.Minute)
+	}
+	dump.Peers[3].LatestHandshake = time.Now().Add(-time.Hour)
+	saveSessionState(t, dump, 0, 1024)
+	dump.Peers[1].Rx += 1024
+	dump.Peers[2].Rx += 1024
+	sessionExclude = []string{"10.0.0.4/32"}
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, sessionResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "peer: 10.0.0.2/32, tx 1KiB without rx, endpoint")
+	assert.NotContains(t, output, "10.0.0.3/32")
+	assert.NotContains(t, output, "10.0.0.4/32")
+	assert.Contains(t, output,
+		"peer: 10.0.0.5/32, tx 1KiB without rx, traffic with expired session, latest handshake: 1h0m0s ago")
+	assert.Contains(t, output, "'expired'=1 'one way'=1")
+}
+
+func TestSessionBroken(t *testing.T) {
+	now := time.Now()
+	prev := &peerState{Time: now.Add(-5 * time.Minute), Rx: 1000, Tx: 1000}
+	t.Cleanup(func() { sessionMinBytes = 0 })
+
+	tests := []struct {
+		name      string
+		handshake time.Time
+		rx, tx    uint64
+		minBytes  byteSize
+		status    int
+		msg       string
+	}{
+		{
+			name:      "idle",
+			handshake: now.Add(-time.Hour),
+			rx:        1000,
+			tx:        1000,
+			status:    monitoringplugin.OK,
+		},
+		{
+			name:      "less of min bytes",
+			handshake: now.Add(-time.Minute),
+			rx:        1000,
+			tx:        1100,
+			minBytes:  1024,
+			status:    monitoringplugin.OK,
+		},
+		{
+			name:      "rx without tx",
+			handshake: now.Add(-time.Minute),
+			rx:        3048,
+			tx:        1000,
+			status:    monitoringplugin.WARNING,
+			msg:       "rx 2KiB without tx",
+		},
+		{
+			name:   "never",
+			rx:     1000,
+			tx:     1148,
+			status: monitoringplugin.CRITICAL,
+			msg:    "tx 148B without rx, traffic without handshake",
+		},
+		{
+			name:      "expired during interval",
+			handshake: now.Add(-6 * time.Minute),
+			rx:        2000,
+			tx:        2000,
+			status:    monitoringplugin.OK,
+		},
+		{
+			name:      "expired before interval",
+			handshake: now.Add(-9 * time.Minute),
+			rx:        2000,
+			tx:        2000,
+			status:    monitoringplugin.CRITICAL,
+			msg:       "traffic with expired session, latest handshake: 9m0s ago",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionMinBytes = tt.minBytes
+			peer := &wg.DumpPeer{LatestHandshake: tt.handshake, Rx: tt.rx, Tx: tt.tx}
+			status, msg := sessionBroken(peer, prev, newPeerState(peer, now))
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.msg, msg)
+		})
+	}
+}
+
+func TestSessionResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	err := sessionResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "--state is required")
+
+	useSessionState(t)
+	state, err := loadState(sessionState)
+	require.NoError(t, err)
+	state.SetPeer(&dump.Peers[0], &peerState{Time: time.Now().Add(time.Hour)})
+	require.NoError(t, state.Save(sessionState))
+	err = sessionResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "time of previous state in the future")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are units of byteSize. Binary units are multiples of 1024 and
+// decimal units are multiples of 1000.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+var binaryUnits = [...]struct {
+	Name string
+	Size uint64
+}{
+	{Name: "TiB", Size: 1 << 40},
+	{Name: "GiB", Size: 1 << 30},
+	{Name: "MiB", Size: 1 << 20},
+	{Name: "KiB", Size: 1 << 10},
+}
+
+// byteSize is a number of bytes, which can be given with units, like 100GiB or
+// 1.5MB. It implements pflag.Value.
+type byteSize uint64
+
+func parseByteSize(s string) (byteSize, error) {
+	i := strings.LastIndexAny(s, "0123456789.") + 1
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit of size %q", s)
+	}
+
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: %w", s, err)
+	} else if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return byteSize(math.Round(v * float64(mult))), nil
+}
+
+func (self *byteSize) Set(s string) error {
+	v, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*self = v
+	return nil
+}
+
+func (self *byteSize) String() string {
+	if *self == 0 {
+		return "0"
+	}
+	return formatBytes(uint64(*self))
+}
+
+func (self *byteSize) Type() string { return "size" }
+
+// formatBytes returns n as human readable string, using binary units, like
+// 1.5GiB.
+func formatBytes(n uint64) string {
+	for _, u := range binaryUnits {
+		if n >= u.Size {
+			s := strconv.FormatFloat(float64(n)/float64(u.Size), 'f', 2, 64)
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+			return s + u.Name
+		}
+	}
+	return strconv.FormatUint(n, 10) + "B"
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want byteSize
+	}{
+		{s: "0", want: 0},
+		{s: "100", want: 100},
+		{s: "100B", want: 100},
+		{s: "1KB", want: 1000},
+		{s: "1.5MB", want: 1_500_000},
+		{s: "2GB", want: 2_000_000_000},
+		{s: "1TB", want: 1_000_000_000_000},
+		{s: "1KiB", want: 1024},
+		{s: "1.5MiB", want: 1536 * 1024},
+		{s: "100GiB", want: 100 << 30},
+		{s: "1tib", want: 1 << 40},
+		{s: "10 MiB", want: 10 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseByteSize(tt.s)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseByteSize_errors(t *testing.T) {
+	tests := []struct {
+		s       string
+		wantErr string
+	}{
+		{s: "", wantErr: "parse size"},
+		{s: "GiB", wantErr: "parse size"},
+		{s: "10XB", wantErr: "unknown unit"},
+		{s: "-1MiB", wantErr: "invalid size"},
+		{s: "1..5GiB", wantErr: "parse size"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			_, err := parseByteSize(tt.s)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestByteSize_flag(t *testing.T) {
+	var v byteSize
+	require.NoError(t, v.Set("1.5GiB"))
+	assert.Equal(t, byteSize(1536<<20), v)
+	assert.Equal(t, "1.5GiB", v.String())
+	assert.Equal(t, "size", v.Type())
+	v = 0
+	assert.Equal(t, "0", v.String())
+	require.Error(t, v.Set("foo"))
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{n: 0, want: "0B"},
+		{n: 1023, want: "1023B"},
+		{n: 1024, want: "1KiB"},
+		{n: 1536, want: "1.5KiB"},
+		{n: 10 << 20, want: "10MiB"},
+		{n: 1_000_000_000, want: "953.67MiB"},
+		{n: 100 << 30, want: "100GiB"},
+		{n: 2 << 40, want: "2TiB"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatBytes(tt.n))
+	}
+}
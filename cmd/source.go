@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// Source obtains wg.Dump. Meaning of args depends on Source: command to
+// execute, file name, interface names and so on.
+type Source interface {
+	Dump(args []string) (wg.Dump, error)
+}
+
+// SourceFunc is an adapter, which allows to use ordinary function as Source.
+type SourceFunc func(args []string) (wg.Dump, error)
+
+func (self SourceFunc) Dump(args []string) (wg.Dump, error) {
+	return self(args)
+}
+
+// StaticSource is a Source, which always returns the same dump, without any
+// args.
+type StaticSource wg.Dump
+
+func (self *StaticSource) Dump(args []string) (wg.Dump, error) {
+	if len(args) != 0 {
+		return wg.Dump{}, fmt.Errorf("unexpected args: %v", args)
+	}
+	dump := wg.Dump(*self)
+	dump.Peers = slices.Clone(dump.Peers)
+	return dump, nil
+}
+
+var sources = map[string]Source{
+	"auto":  SourceFunc(autoDump),
+	"cache": SourceFunc(cacheDump),
+	"exec":  SourceFunc(execDump),
+	"file":  SourceFunc(fileDump),
+	"stdin": SourceFunc(stdinDump),
+	"uapi":  SourceFunc(uapiDump),
+}
+
+// RegisterSource makes Source available by given name for --source flag. It
+// panics if Source with the same name already registered.
+func RegisterSource(name string, src Source) {
+	if _, ok := sources[name]; ok {
+		panic("cmd: RegisterSource called twice for source " + name)
+	}
+	sources[name] = src
+}
+
+func sourceNames() []string {
+	return slices.Sorted(maps.Keys(sources))
+}
+
+func lookupSource(name string) (Source, error) {
+	src, ok := sources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown source: %s", name)
+	}
+	return src, nil
+}
+
+// NewWgDump returns wg.Dump from Source selected by --source flag.
+func NewWgDump(args []string) (wg.Dump, error) {
+	src, err := lookupSource(sourceName)
+	if err != nil {
+		return wg.Dump{}, err
+	}
+	return src.Dump(args)
+}
+
+// autoDump reads UAPI sockets, if --uapi given, or executes given command, or
+// reads stdin if no command given.
+func autoDump(args []string) (wg.Dump, error) {
+	switch {
+	case len(uapiSockets) != 0:
+		return uapiDump(args)
+	case len(args) == 0:
+		return stdinDump(args)
+	}
+	return execDump(args)
+}
+
+func stdinDump(args []string) (wg.Dump, error) {
+	if len(args) != 0 {
+		return wg.Dump{}, fmt.Errorf("unexpected args with stdin source: %v",
+			args)
+	}
+
+	dump, err := parseWgDump(os.Stdin)
+	if err != nil {
+		return wg.Dump{}, fmt.Errorf("with input from stdin: %w", err)
+	}
+	return dump, nil
+}
+
+func execDump(args []string) (dump wg.Dump, err error) {
+	if len(args) == 0 {
+		return wg.Dump{}, errors.New("exec source requires command to execute")
+	}
+
+	err = withWgCmd(args, func(r io.Reader) error {
+		dump, err = parseWgDump(r)
+		if err != nil {
+			return fmt.Errorf("with input from %v: %w", args, err)
+		}
+		return nil
+	})
+	return
+}
+
+func fileDump(args []string) (wg.Dump, error) {
+	if len(args) != 1 {
+		return wg.Dump{}, fmt.Errorf(
+			"file source requires exactly one file name, got: %v", args)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return wg.Dump{}, fmt.Errorf("open dump file: %w", err)
+	}
+	defer f.Close()
+
+	dump, err := parseWgDump(f)
+	if err != nil {
+		return wg.Dump{}, fmt.Errorf("with input from %v: %w", args[0], err)
+	}
+	return dump, nil
+}
+
+// uapiDump reads interfaces from UAPI sockets given by --uapi and args.
+func uapiDump(args []string) (wg.Dump, error) {
+	sockets := slices.Concat(uapiSockets, args)
+	if len(sockets) == 0 {
+		return wg.Dump{}, errors.New("uapi source requires interface name")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var all wg.AllDump
+	for _, s := range sockets {
+		name, path := wg.UAPISocket(s)
+		dump, err := wg.ReadUAPI(ctx, path)
+		if err != nil {
+			return wg.Dump{}, fmt.Errorf("with input from %v: %w", path, err)
+		} else if err := all.Add(name, dump); err != nil {
+			return wg.Dump{}, err
+		}
+	}
+	return all.Dump(interfaces...)
+}
+
+// cacheDump reads output of wg(8) from --cache-file, if it's not older than
+// --cache-ttl. Otherwise it executes given command and saves its output into
+// --cache-file.
+func cacheDump(args []string) (wg.Dump, error) {
+	if cacheFile == "" {
+		return wg.Dump{}, errors.New("cache source requires --cache-file")
+	}
+
+	fi, err := os.Stat(cacheFile)
+	if err == nil && time.Since(fi.ModTime()) < cacheTTL {
+		return fileDump([]string{cacheFile})
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return wg.Dump{}, fmt.Errorf("stat cache file: %w", err)
+	} else if len(args) == 0 {
+		return wg.Dump{}, errors.New("cache source requires command to execute")
+	}
+
+	var b []byte
+	err = withWgCmd(args, func(r io.Reader) error {
+		b, err = io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("read output of %v: %w", args, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return wg.Dump{}, err
+	}
+
+	dump, err := parseWgDump(bytes.NewReader(b))
+	if err != nil {
+		return wg.Dump{}, fmt.Errorf("with input from %v: %w", args, err)
+	} else if err := writeFileAtomic(cacheFile, b, 0o600); err != nil {
+		return wg.Dump{}, err
+	}
+	return dump, nil
+}
+
+func parseWgDump(r io.Reader) (wg.Dump, error) {
+	if !allInterfaces && len(interfaces) == 0 {
+		return wg.NewDump(r)
+	}
+
+	all, err := wg.NewAllDump(r)
+	if err != nil {
+		return wg.Dump{}, err
+	}
+	return all.Dump(interfaces...)
+}
+
+func withWgCmd(args []string, fn func(r io.Reader) error) error {
+	r, cmd, err := startWgCmd(args)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(r); err != nil {
+		return err
+	}
+
+	if cmd != nil {
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("wait for %v: %w", args, err)
+		}
+	}
+	return nil
+}
+
+func startWgCmd(args []string) (io.Reader, *exec.Cmd, error) {
+	if len(args) == 0 {
+		return os.Stdin, nil, nil
+	}
+
+	var cmdArgs []string
+	if len(args) > 1 {
+		cmdArgs = args[1:]
+	}
+
+	cmd := exec.Command(args[0], cmdArgs...)
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	const errMsg = "exec %v: %w"
+	if err != nil {
+		return nil, nil, fmt.Errorf(errMsg, args, err)
+	} else if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf(errMsg, args, err)
+	}
+	return stdout, cmd, nil
+}
+
+// writeFileAtomic writes b into temporary file in the same directory and
+// renames it to name, so readers never see partially written file.
+func writeFileAtomic(name string, b []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("create temp file for %q: %w", name, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("write %q: %w", f.Name(), err)
+	} else if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("chmod %q: %w", f.Name(), err)
+	} else if err := f.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", f.Name(), err)
+	} else if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("rename %q to %q: %w", f.Name(), name, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestWgDump_errors(t *testing.T) {
+	_, err := NewWgDump([]string{"cat", "/dev/null"})
+	require.ErrorContains(t, err, "with input from")
+
+	devnull, err := os.Open("/dev/null")
+	require.NoError(t, err)
+	t.Cleanup(func() { devnull.Close() })
+
+	stdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = stdin })
+	os.Stdin = devnull
+
+	_, err = NewWgDump([]string{})
+	require.ErrorContains(t, err, "with input from stdin")
+}
+
+func TestWithWgCmd(t *testing.T) {
+	err := withWgCmd([]string{}, func(r io.Reader) error {
+		assert.Same(t, r, os.Stdin)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var got string
+	err = withWgCmd([]string{"echo", "foobar"}, func(r io.Reader) error {
+		b, err := io.ReadAll(r)
+		got = string(b)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "foobar\n", got)
+
+	wantErr := errors.New("test error")
+	err = withWgCmd([]string{}, func(r io.Reader) error {
+		return wantErr
+	})
+	require.ErrorIs(t, err, wantErr)
+
+	err = withWgCmd([]string{""}, func(r io.Reader) error {
+		return nil
+	})
+	require.ErrorContains(t, err, "exec: no command")
+
+	err = withWgCmd([]string{"sh", "-c", "exit 1"}, func(r io.Reader) error {
+		return nil
+	})
+	require.ErrorContains(t, err, "wait for")
+}
+
+func TestWgDump_allInterfaces(t *testing.T) {
+	t.Cleanup(func() { allInterfaces, interfaces = false, nil })
+	args := []string{"cat", "../wg/testdata/wg_show_all_dump.txt"}
+
+	_, err := NewWgDump(args)
+	require.ErrorContains(t, err, "with input from")
+
+	allInterfaces = true
+	dump, err := NewWgDump(args)
+	require.NoError(t, err)
+	assert.Len(t, dump.Peers, 3)
+
+	allInterfaces, interfaces = false, []string{"wg1"}
+	dump, err = NewWgDump(args)
+	require.NoError(t, err)
+	require.Len(t, dump.Peers, 1)
+	assert.Equal(t, "wg1", dump.Peers[0].Interface)
+	assert.Equal(t, uint16(12346), dump.ListenPort)
+
+	interfaces = []string{"wg2"}
+	_, err = NewWgDump(args)
+	require.ErrorContains(t, err, "interface not found: wg2")
+}
+
+func TestWgDump_uapi(t *testing.T) {
+	t.Cleanup(func() { uapiSockets, interfaces = nil, nil })
+	dir := t.TempDir()
+	for _, name := range []string{"wg0", "wg1"} {
+		serveUAPI(t, filepath.Join(dir, name+".sock"),
+			"../wg/testdata/uapi_get.txt")
+	}
+
+	uapiSockets = []string{filepath.Join(dir, "wg0.sock")}
+	dump, err := NewWgDump([]string{filepath.Join(dir, "wg1.sock")})
+	require.NoError(t, err)
+	require.Len(t, dump.Peers, 4)
+	assert.Equal(t, "wg0", dump.Peers[0].Interface)
+	assert.Equal(t, "wg1", dump.Peers[3].Interface)
+
+	uapiSockets = []string{filepath.Join(dir, "wg2.sock")}
+	_, err = NewWgDump(nil)
+	require.ErrorContains(t, err, "with input from "+uapiSockets[0])
+}
+
+func serveUAPI(t *testing.T, path, dataPath string) {
+	t.Helper()
+	b, err := os.ReadFile(dataPath)
+	require.NoError(t, err)
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == "\n" {
+					break
+				}
+			}
+			_, _ = conn.Write(b)
+			conn.Close()
+		}
+	}()
+}
+
+// useSource registers src for the time of test and selects it as --source.
+func useSource(t *testing.T, src Source) {
+	t.Helper()
+	name := t.Name()
+	RegisterSource(name, src)
+	sourceName = name
+	t.Cleanup(func() {
+		delete(sources, name)
+		sourceName = "auto"
+	})
+}
+
+// useDumpFile parses wg show dump output from file and selects it as --source.
+func useDumpFile(t *testing.T, name string) *wg.Dump {
+	t.Helper()
+	dump, err := fileDump([]string{name})
+	require.NoError(t, err)
+	useSource(t, (*StaticSource)(&dump))
+	return &dump
+}
+
+func TestLookupSource(t *testing.T) {
+	for _, name := range sourceNames() {
+		src, err := lookupSource(name)
+		require.NoError(t, err)
+		assert.NotNil(t, src)
+	}
+
+	_, err := lookupSource("foobar")
+	require.ErrorContains(t, err, "unknown source: foobar")
+
+	sourceName = "foobar"
+	t.Cleanup(func() { sourceName = "auto" })
+	_, err = NewWgDump(nil)
+	require.ErrorContains(t, err, "unknown source: foobar")
+}
+
+func TestRegisterSource(t *testing.T) {
+	dump := wg.Dump{ListenPort: 12345, Peers: []wg.DumpPeer{{Rx: 1}}}
+	useSource(t, (*StaticSource)(&dump))
+
+	got, err := NewWgDump(nil)
+	require.NoError(t, err)
+	assert.Equal(t, dump, got)
+	got.Peers[0].Rx = 2
+	assert.Equal(t, uint64(1), dump.Peers[0].Rx)
+
+	_, err = NewWgDump([]string{"foobar"})
+	require.ErrorContains(t, err, "unexpected args")
+
+	assert.Panics(t, func() { RegisterSource("auto", SourceFunc(autoDump)) })
+}
+
+func TestSource_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  SourceFunc
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "stdin with args",
+			source:  stdinDump,
+			args:    []string{"cat"},
+			wantErr: "unexpected args with stdin source",
+		},
+		{
+			name:    "exec without command",
+			source:  execDump,
+			wantErr: "exec source requires command",
+		},
+		{
+			name:    "file without name",
+			source:  fileDump,
+			wantErr: "file source requires exactly one file name",
+		},
+		{
+			name:    "file not exists",
+			source:  fileDump,
+			args:    []string{"testdata/not_exists.txt"},
+			wantErr: "open dump file",
+		},
+		{
+			name:    "file parse error",
+			source:  fileDump,
+			args:    []string{"/dev/null"},
+			wantErr: "with input from /dev/null",
+		},
+		{
+			name:    "uapi without interface",
+			source:  uapiDump,
+			wantErr: "uapi source requires interface name",
+		},
+		{
+			name:    "cache without file",
+			source:  cacheDump,
+			wantErr: "cache source requires --cache-file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.source.Dump(tt.args)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestFileDump(t *testing.T) {
+	dump, err := fileDump([]string{"../wg/testdata/wg_show_dump.txt"})
+	require.NoError(t, err)
+	assert.Len(t, dump.Peers, 4)
+}
+
+func TestCacheDump(t *testing.T) {
+	cacheFile = filepath.Join(t.TempDir(), "wg.dump")
+	t.Cleanup(func() { cacheFile = "" })
+
+	_, err := cacheDump(nil)
+	require.ErrorContains(t, err, "cache source requires command")
+
+	_, err = cacheDump([]string{"cat", "/dev/null"})
+	require.ErrorContains(t, err, "with input from [cat /dev/null]")
+	assert.NoFileExists(t, cacheFile)
+
+	dump, err := cacheDump([]string{"cat", "../wg/testdata/wg_show_dump.txt"})
+	require.NoError(t, err)
+	assert.Len(t, dump.Peers, 4)
+	require.FileExists(t, cacheFile)
+	fi, err := os.Stat(cacheFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	dump, err = cacheDump([]string{"cat", "/dev/null"})
+	require.NoError(t, err, "must be read from cache file")
+	assert.Len(t, dump.Peers, 4)
+
+	expired := time.Now().Add(-cacheTTL)
+	require.NoError(t, os.Chtimes(cacheFile, expired, expired))
+	dump, err = cacheDump(
+		[]string{"cat", "../wg/testdata/latest_handshake_zero.txt"})
+	require.NoError(t, err)
+	assert.Len(t, dump.Peers, 1)
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+// checkState is saved by a check into its state file between runs. Peers are
+// keyed by interface and public key, see stateKey.
+type checkState struct {
+	// Command is name of the command, which owns the state file. Peers are
+	// snapshots of the command, so another command can't share its state.
+	Command string                `json:"command,omitempty"`
+	Peers   map[string]*peerState `json:"peers"`
+}
+
+// peerState is a snapshot of peer, saved by previous run of a check.
+type peerState struct {
+	Time time.Time `json:"time"`
+	Rx   uint64    `json:"rx"`
+	Tx   uint64    `json:"tx"`
+
+	Endpoint netip.AddrPort `json:"endpoint,omitzero"`
+	// NAT is true, if port of endpoint changed between runs once.
+	NAT bool `json:"nat,omitempty"`
+	// Roams are times of endpoint changes, detected by previous runs.
+	Roams []time.Time `json:"roams,omitempty"`
+
+	Quota *quotaState `json:"quota,omitempty"`
+}
+
+// newPeerState returns snapshot of peer at given time.
+func newPeerState(peer *wg.DumpPeer, now time.Time) *peerState {
+	return &peerState{
+		Time:     now,
+		Rx:       peer.Rx,
+		Tx:       peer.Tx,
+		Endpoint: peer.Endpoint,
+	}
+}
+
+// Delta returns bytes transferred since prev. If counters decreased, because
+// interface was restarted, it returns current counters and reset is true.
+func (self *peerState) Delta(prev *peerState) (rx, tx uint64, reset bool) {
+	if self.Rx < prev.Rx || self.Tx < prev.Tx {
+		return self.Rx, self.Tx, true
+	}
+	return self.Rx - prev.Rx, self.Tx - prev.Tx, false
+}
+
+// loadState reads state file. It returns empty state if the file doesn't exist
+// yet.
+func loadState(name string) (*checkState, error) {
+	self := &checkState{}
+	b, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read state: %w", err)
+	} else if err == nil {
+		if err := json.Unmarshal(b, self); err != nil {
+			return nil, fmt.Errorf("parse state file %q: %w", name, err)
+		}
+	}
+
+	if self.Peers == nil {
+		self.Peers = make(map[string]*peerState)
+	}
+	return self, nil
+}
+
+// Save atomically writes state into file.
+func (self *checkState) Save(name string) error {
+	b, err := json.Marshal(self)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	return writeFileAtomic(name, b, 0o600)
+}
+
+// withState locks state file of command, loads it, calls fn and saves state
+// modified by fn. The lock is held on separate name + ".lock" file, because
+// Save replaces state file, and it serializes concurrent runs of checks sharing
+// the same state file, so they don't lose updates of each other. It returns
+// error if the state file belongs to another command.
+func withState(name, command string, fn func(state *checkState) error) error {
+	f, err := os.OpenFile(name+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open lock of state: %w", err)
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("lock state %q: %w", name, err)
+	}
+
+	state, err := loadState(name)
+	if err != nil {
+		return err
+	} else if state.Command != "" && state.Command != command {
+		return fmt.Errorf("state file %q belongs to %s command", name,
+			state.Command)
+	}
+
+	state.Command = command
+	if err := fn(state); err != nil {
+		return err
+	}
+	return state.Save(name)
+}
+
+// updatePeersState saves snapshot of every peer into state file and calls fn
+// for every peer, which has snapshot of previous run. Fn returns true, if it
+// checked the peer, and false for excluded peers. It returns false, if no peer
+// was checked, like on the first run.
+func updatePeersState(name, command string, dump *wg.Dump,
+	fn func(peer *wg.DumpPeer, prev, cur *peerState) (bool, error),
+) (bool, error) {
+	var hasPrev bool
+	err := withState(name, command, func(state *checkState) error {
+		now := time.Now()
+		for i := range dump.Peers {
+			peer := &dump.Peers[i]
+			prev, cur := state.Peer(peer), newPeerState(peer, now)
+			state.SetPeer(peer, cur)
+			if prev == nil {
+				continue
+			} else if !now.After(prev.Time) {
+				return fmt.Errorf("time of previous state in the future: %v",
+					prev.Time)
+			}
+
+			checked, err := fn(peer, prev, cur)
+			if err != nil {
+				return err
+			}
+			hasPrev = hasPrev || checked
+		}
+		return nil
+	})
+	return hasPrev, err
+}
+
+// Peer returns saved state of peer or nil.
+func (self *checkState) Peer(peer *wg.DumpPeer) *peerState {
+	return self.Peers[stateKey(peer)]
+}
+
+func (self *checkState) SetPeer(peer *wg.DumpPeer, st *peerState) {
+	self.Peers[stateKey(peer)] = st
+}
+
+func stateKey(peer *wg.DumpPeer) string {
+	if peer.Interface == "" {
+		return peer.PublicKey
+	}
+	return peer.Interface + "/" + peer.PublicKey
+}
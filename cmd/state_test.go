@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+func TestCheckState(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "state.json")
+	state, err := loadState(name)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Empty(t, state.Peers)
+
+	peer := &wg.DumpPeer{PublicKey: "A", Rx: 1, Tx: 2}
+	assert.Nil(t, state.Peer(peer))
+
+	now := time.Now().UTC().Round(0)
+	st := &peerState{Time: now, Rx: peer.Rx, Tx: peer.Tx}
+	state.SetPeer(peer, st)
+	assert.Same(t, st, state.Peer(peer))
+
+	peer2 := &wg.DumpPeer{PublicKey: "A", Interface: "wg1"}
+	assert.Nil(t, state.Peer(peer2))
+	state.SetPeer(peer2, &peerState{Time: now})
+	require.NoError(t, state.Save(name))
+
+	fi, err := os.Stat(name)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	state, err = loadState(name)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*peerState{
+		"A":     {Time: now, Rx: 1, Tx: 2},
+		"wg1/A": {Time: now},
+	}, state.Peers)
+}
+
+func TestLoadState_errors(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(name, []byte("foobar"), 0o600))
+	_, err := loadState(name)
+	require.ErrorContains(t, err, "parse state file")
+
+	_, err = loadState(t.TempDir())
+	require.ErrorContains(t, err, "read state")
+}
+
+func TestWithState(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "state.json")
+
+	var group sync.WaitGroup
+	for i := range 10 {
+		group.Go(func() {
+			peer := &wg.DumpPeer{PublicKey: strconv.Itoa(i)}
+			assert.NoError(t, withState(name, "test", func(state *checkState) error {
+				time.Sleep(time.Millisecond)
+				state.SetPeer(peer, &peerState{Rx: uint64(i)})
+				return nil
+			}))
+		})
+	}
+	group.Wait()
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	assert.Len(t, state.Peers, 10)
+	assert.FileExists(t, name+".lock")
+
+	testErr := errors.New("test error")
+	err = withState(name, "test", func(state *checkState) error {
+		clear(state.Peers)
+		return testErr
+	})
+	require.ErrorIs(t, err, testErr)
+	state, err = loadState(name)
+	require.NoError(t, err)
+	assert.Len(t, state.Peers, 10)
+	assert.Equal(t, "test", state.Command)
+
+	err = withState(name, "other", func(state *checkState) error {
+		t.Fatal("unexpected call")
+		return nil
+	})
+	require.ErrorContains(t, err, "belongs to test command")
+}
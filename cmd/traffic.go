@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/spf13/cobra"
+
+	"github.com/dsh2dsh/check_wg/wg"
+)
+
+var (
+	trafficState     string
+	trafficMinBytes  byteSize = 1 << 20
+	trafficRatioWarn float64
+	trafficRatioCrit float64
+	trafficExclude   []string
+
+	trafficCmd = cobra.Command{
+		Use:   "traffic --state FILE [--min-bytes 1MiB] [--ratio-warn N] [--ratio-crit N] [-x peer]... [wg show wg0 dump]",
+		Short: "check for asymmetric traffic of peers",
+		Long: `It saves counters of peers into given state file and calculates rx and tx of
+every peer since previous run. The first run only creates the state.
+
+It outputs critical status for every peer, which traffic goes in one direction
+only, like a peer receives, but never sends, what usually means a routing
+mistake. With --ratio-warn or --ratio-crit it outputs warning or critical status
+for every peer, which traffic in one direction is greater of given number of
+times of traffic in another direction.
+
+Peers, which transferred less of --min-bytes in both directions together since
+previous run, are idle and ignored.`,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			outputAndExit(monitoringResponse("traffic", args, trafficResponse))
+		},
+	}
+)
+
+func init() {
+	f := trafficCmd.Flags()
+	f.StringVar(&trafficState, "state", "",
+		"file with counters of previous run")
+	f.Var(&trafficMinBytes, "min-bytes",
+		"ignore peers, which transferred less of this since previous run")
+	f.Float64Var(&trafficRatioWarn, "ratio-warn", 0,
+		"warning threshold of ratio between rx and tx")
+	f.Float64Var(&trafficRatioCrit, "ratio-crit", 0,
+		"critical threshold of ratio between rx and tx")
+	f.StringArrayVarP(&trafficExclude, "exclude", "x", nil,
+		"peers or @groups to exclude from check")
+}
+
+// trafficCounts is result of checking traffic of peers.
+type trafficCounts struct {
+	Checked    int
+	Idle       int
+	OneWay     int
+	Asymmetric int
+}
+
+func trafficResponse(dump *wg.Dump, resp *monitoringplugin.Response) error {
+	if trafficState == "" {
+		return errors.New("--state is required")
+	} else if trafficRatioWarn != 0 && trafficRatioCrit != 0 &&
+		trafficRatioWarn > trafficRatioCrit {
+		return errors.New("--ratio-warn greater of --ratio-crit")
+	}
+
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	var counts trafficCounts
+	hasPrev, err := updatePeersState(trafficState, "traffic", dump,
+		func(peer *wg.DumpPeer, prev, cur *peerState) (bool, error) {
+			if idents.MatchAny(peer, trafficExclude) {
+				return false, nil
+			}
+			counts.Checked++
+
+			rx, tx, _ := cur.Delta(prev)
+			status, msg := counts.Add(rx, tx)
+			if status == monitoringplugin.OK {
+				return true, nil
+			}
+			return true, outputPeerDetails(peer, status, msg, resp)
+		})
+
+	if err != nil {
+		return err
+	} else if !hasPrev {
+		resp.WithDefaultOkMessage(
+			"no previous state, traffic will be checked by next run")
+	} else {
+		resp.WithDefaultOkMessage(fmt.Sprintf(
+			"no asymmetric traffic of %d peers, %d idle", counts.Checked,
+			counts.Idle))
+	}
+	return counts.PerfData(resp)
+}
+
+// Add counts traffic of peer since previous run and returns its status and
+// description, if it's asymmetric.
+func (self *trafficCounts) Add(rx, tx uint64) (int, string) {
+	if rx+tx < uint64(trafficMinBytes) || rx+tx == 0 {
+		self.Idle++
+		return monitoringplugin.OK, ""
+	}
+
+	switch {
+	case tx == 0:
+		self.OneWay++
+		return monitoringplugin.CRITICAL, "rx " + formatBytes(rx) + " without tx"
+	case rx == 0:
+		self.OneWay++
+		return monitoringplugin.CRITICAL, "tx " + formatBytes(tx) + " without rx"
+	}
+
+	ratio := float64(max(rx, tx)) / float64(min(rx, tx))
+	var status int
+	switch {
+	case trafficRatioCrit != 0 && ratio > trafficRatioCrit:
+		status = monitoringplugin.CRITICAL
+	case trafficRatioWarn != 0 && ratio > trafficRatioWarn:
+		status = monitoringplugin.WARNING
+	default:
+		return monitoringplugin.OK, ""
+	}
+
+	self.Asymmetric++
+	return status, fmt.Sprintf("rx %s, tx %s, ratio %s", formatBytes(rx),
+		formatBytes(tx), strconv.FormatFloat(ratio, 'f', 1, 64))
+}
+
+func (self *trafficCounts) PerfData(resp *monitoringplugin.Response) error {
+	points := [...]*monitoringplugin.PerformanceDataPoint[int]{
+		monitoringplugin.NewPerformanceDataPoint("one way", self.OneWay),
+		monitoringplugin.NewPerformanceDataPoint("asymmetric", self.Asymmetric),
+		monitoringplugin.NewPerformanceDataPoint("idle", self.Idle),
+		monitoringplugin.NewPerformanceDataPoint("checked", self.Checked),
+	}
+
+	for _, point := range points {
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				point.Metric, point.Value, err)
+		}
+	}
+	return nil
+}
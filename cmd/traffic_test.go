@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dsh2dsh/go-monitoringplugin/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTrafficState(t *testing.T) string {
+	t.Helper()
+	trafficState = filepath.Join(t.TempDir(), "traffic.json")
+	t.Cleanup(func() {
+		trafficState, trafficMinBytes, trafficExclude = "", 1<<20, nil
+		trafficRatioWarn, trafficRatioCrit = 0, 0
+	})
+	return trafficState
+}
+
+func TestTrafficResponse(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useTrafficState(t)
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, trafficResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no previous state, traffic will be checked by next run | 'one way'=0 'asymmetric'=0 'idle'=0 'checked'=0",
+		resp.GetInfo().RawOutput)
+
+	for i := range dump.Peers[:3] {
+		dump.Peers[i].Rx += 1 << 20
+		dump.Peers[i].Tx += 1 << 19
+	}
+	dump.Peers[3].Rx += 10
+
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, trafficResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Equal(t,
+		"OK: no asymmetric traffic of 4 peers, 1 idle | 'one way'=0 'asymmetric'=0 'idle'=1 'checked'=4",
+		resp.GetInfo().RawOutput)
+}
+
+func TestTrafficResponse_asymmetric(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	useTrafficState(t)
+	require.NoError(t, trafficResponse(dump,
+		monitoringplugin.NewResponse("test OK")))
+
+	dump.Peers[0].Rx += 2 << 20
+	dump.Peers[1].Tx += 3 << 20
+	dump.Peers[2].Rx += 20 << 20
+	dump.Peers[2].Tx += 1 << 20
+	dump.Peers[3].Rx += 60 << 20
+	dump.Peers[3].Tx += 1 << 20
+	trafficRatioWarn, trafficRatioCrit = 10, 50
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, trafficResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.CRITICAL, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "peer: 10.0.0.2/32, rx 2MiB without tx")
+	assert.Contains(t, output, "peer: 10.0.0.3/32, tx 3MiB without rx")
+	assert.Contains(t, output, "peer: 10.0.0.4/32, rx 20MiB, tx 1MiB, ratio 20.0")
+	assert.Contains(t, output, "peer: 10.0.0.5/32, rx 60MiB, tx 1MiB, ratio 60.0")
+	assert.Contains(t, output,
+		"'one way'=2 'asymmetric'=2 'idle'=0 'checked'=4")
+
+	// the same counters, so all peers are idle now.
+	trafficExclude = []string{"10.0.0.2/32"}
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, trafficResponse(dump, resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput,
+		"'one way'=0 'asymmetric'=0 'idle'=3 'checked'=3")
+}
+
+func TestTrafficCounts_Add(t *testing.T) {
+	t.Cleanup(func() { trafficRatioWarn, trafficRatioCrit = 0, 0 })
+	trafficRatioWarn = 10
+
+	var counts trafficCounts
+	status, msg := counts.Add(0, 0)
+	assert.Equal(t, monitoringplugin.OK, status)
+	assert.Empty(t, msg)
+
+	status, _ = counts.Add(10<<20, 2<<20)
+	assert.Equal(t, monitoringplugin.OK, status)
+
+	status, msg = counts.Add(2<<20, 100<<10)
+	assert.Equal(t, monitoringplugin.WARNING, status)
+	assert.Equal(t, "rx 2MiB, tx 100KiB, ratio 20.5", msg)
+	assert.Equal(t, trafficCounts{Idle: 1, Asymmetric: 1}, counts)
+}
+
+func TestTrafficResponse_errors(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	err := trafficResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "--state is required")
+
+	useTrafficState(t)
+	trafficRatioWarn, trafficRatioCrit = 10, 5
+	err = trafficResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "--ratio-warn greater of --ratio-crit")
+
+	trafficRatioWarn, trafficRatioCrit = 0, 0
+	state, err := loadState(trafficState)
+	require.NoError(t, err)
+	state.SetPeer(&dump.Peers[0], &peerState{Time: time.Now().Add(time.Hour)})
+	require.NoError(t, state.Save(trafficState))
+	err = trafficResponse(dump, monitoringplugin.NewResponse("test OK"))
+	require.ErrorContains(t, err, "time of previous state in the future")
+}
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/dsh2dsh/go-monitoringplugin/v2"
 	"github.com/spf13/cobra"
@@ -9,32 +13,87 @@ import (
 	"github.com/dsh2dsh/check_wg/wg"
 )
 
-var transferCmd = cobra.Command{
-	Use:   "transfer [flags] PEER [wg show wg0 dump]",
-	Short: "Outputs transfer stats",
-	Args:  cobra.MinimumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		peerName := args[0]
-		var peerArgs []string
-		if len(args) > 1 {
-			peerArgs = args[1:]
-		}
-		monitoringResponse("bytes transferred", peerArgs,
-			func(dump *wg.Dump, resp *monitoringplugin.Response) error {
-				return transferResponse(dump, peerName, resp)
-			}).
-			OutputAndExit()
-	},
+var (
+	transferState  string
+	transferRxWarn byteSize
+	transferRxCrit byteSize
+	transferTxWarn byteSize
+	transferTxCrit byteSize
+
+	transferQuota          byteSize
+	transferQuotaPeriod    string
+	transferQuotaDirection string
+	transferQuotaWarn      float64
+	transferQuotaCrit      float64
+
+	transferCmd = cobra.Command{
+		Use:   "transfer [--state FILE [--quota 100GiB]] PEER [wg show wg0 dump]",
+		Short: "Outputs transfer stats",
+		Long: `Outputs transfer stats of given peer. Peer can be given by its alias,
+name, public key or by any IP address, routed by the peer.
+
+With --state it saves counters of the peer into given file and calculates rx/tx
+rates in bytes per second since previous run. The first run only creates the
+state. If counters decreased, because interface was restarted, current
+counters are used as transferred since previous run. Thresholds of rates are
+given as size per second, like 10MiB. Concurrent runs with the same state file
+are serialized by lock of FILE.lock, so it can be shared by checks of multiple
+peers.
+
+With --quota it accumulates traffic of the peer since beginning of current day,
+week or month into the state and outputs warning or critical status, if given
+percentage of the quota consumed. Traffic of the first run, without previous
+state, is not accounted. Runs without --quota keep accumulating quota of the
+peer, which is already in the state.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			peerName := args[0]
+			var peerArgs []string
+			if len(args) > 1 {
+				peerArgs = args[1:]
+			}
+			outputAndExit(monitoringResponse("bytes transferred", peerArgs,
+				func(dump *wg.Dump, resp *monitoringplugin.Response) error {
+					return transferResponse(dump, peerName, resp)
+				}))
+		},
+	}
+)
+
+func init() {
+	f := transferCmd.Flags()
+	f.StringVar(&transferState, "state", "",
+		"file with counters of previous run for calculating rates")
+	f.Var(&transferRxWarn, "rx-warn", "warning threshold of rx rate per second")
+	f.Var(&transferRxCrit, "rx-crit", "critical threshold of rx rate per second")
+	f.Var(&transferTxWarn, "tx-warn", "warning threshold of tx rate per second")
+	f.Var(&transferTxCrit, "tx-crit", "critical threshold of tx rate per second")
+
+	f.Var(&transferQuota, "quota", "volume quota of the peer, like 100GiB")
+	f.StringVar(&transferQuotaPeriod, "quota-period", "month",
+		"period of quota: "+strings.Join(quotaPeriods, ", "))
+	f.StringVar(&transferQuotaDirection, "quota-direction", "total",
+		"traffic accounted by quota: total, rx or tx")
+	f.Float64Var(&transferQuotaWarn, "quota-warn", 80,
+		"warning threshold of consumed quota in percents")
+	f.Float64Var(&transferQuotaCrit, "quota-crit", 100,
+		"critical threshold of consumed quota in percents")
 }
 
 func transferResponse(dump *wg.Dump, name string,
 	resp *monitoringplugin.Response,
 ) error {
-	peer := dump.Peer(name)
-	if peer == nil {
-		return fmt.Errorf("peer not found: %s", name)
+	idents, err := newPeerIdents()
+	if err != nil {
+		return err
+	}
+
+	peer, prefix, err := findPeer(dump, idents, name)
+	if err != nil {
+		return err
 	}
 	resp.WithDefaultOkMessage(fmt.Sprintf("peer=%v", peer.Name()))
+	outputRoute(name, prefix, resp)
 
 	points := [...]struct {
 		Label string
@@ -53,5 +112,92 @@ func transferResponse(dump *wg.Dump, name string,
 				pd.Label, pd.Bytes, err)
 		}
 	}
+
+	if transferState == "" {
+		if transferQuota != 0 {
+			return errors.New("--quota requires --state")
+		}
+		return nil
+	} else if transferQuota != 0 {
+		if err := checkQuotaFlags(); err != nil {
+			return err
+		}
+	}
+	return transferWithState(peer, resp)
+}
+
+func transferWithState(peer *wg.DumpPeer, resp *monitoringplugin.Response,
+) error {
+	var prev, cur *peerState
+	err := withState(transferState, "transfer", func(state *checkState) error {
+		now := time.Now()
+		prev = state.Peer(peer)
+		if prev != nil && !now.After(prev.Time) {
+			return fmt.Errorf("time of previous state in the future: %v",
+				prev.Time)
+		}
+
+		cur = newPeerState(peer, now)
+		if transferQuota != 0 {
+			if err := cur.AddQuota(prev, transferQuotaPeriod); err != nil {
+				return err
+			}
+		} else if prev != nil && prev.Quota != nil {
+			// Keep accounting quota of runs with --quota, which share the same
+			// state file.
+			if err := cur.AddQuota(prev, prev.Quota.Period); err != nil {
+				return err
+			}
+		}
+		state.SetPeer(peer, cur)
+		return nil
+	})
+	if err != nil {
+		return err
+	} else if prev == nil {
+		resp.UpdateStatus(resp.GetStatusCode(),
+			"no previous state, rates will be calculated by next run")
+	} else if err := transferRatesOutput(prev, cur, resp); err != nil {
+		return err
+	}
+
+	if transferQuota != 0 {
+		return transferQuotaOutput(cur.Quota, resp)
+	}
+	return nil
+}
+
+func transferRatesOutput(prev, cur *peerState, resp *monitoringplugin.Response,
+) error {
+	rx, tx, reset := cur.Delta(prev)
+	if reset {
+		resp.UpdateStatus(resp.GetStatusCode(), "counters reset since previous run")
+	}
+
+	points := [...]struct {
+		Metric     string
+		Bytes      uint64
+		Warn, Crit byteSize
+	}{
+		{Metric: "rx rate", Bytes: rx, Warn: transferRxWarn, Crit: transferRxCrit},
+		{Metric: "tx rate", Bytes: tx, Warn: transferTxWarn, Crit: transferTxCrit},
+	}
+
+	interval := cur.Time.Sub(prev.Time)
+	for i := range points {
+		pd := &points[i]
+		rate := uint64(math.Round(float64(pd.Bytes) / interval.Seconds()))
+		point := monitoringplugin.NewPerformanceDataPoint(pd.Metric, rate).
+			SetUnit("b")
+		point.NewThresholds(0, uint64(pd.Warn), 0, uint64(pd.Crit)).
+			UseWarning(pd.Warn != 0, pd.Warn != 0).
+			UseCritical(pd.Crit != 0, pd.Crit != 0)
+		if err := resp.AddPerformanceDataPoint(point); err != nil {
+			return fmt.Errorf("add performance point %s=%v: %w",
+				pd.Metric, rate, err)
+		}
+		resp.UpdateStatus(resp.GetStatusCode(),
+			fmt.Sprintf("%s: %s/s", pd.Metric, formatBytes(rate)))
+	}
 	return nil
 }
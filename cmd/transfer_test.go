@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/dsh2dsh/go-monitoringplugin/v2"
 	"github.com/stretchr/testify/assert"
@@ -31,3 +35,125 @@ func TestTransferResponse(t *testing.T) {
 	require.ErrorContains(t, transferResponse(&dump, "foobar", resp),
 		"peer not found: foobar")
 }
+
+func TestTransferResponse_route(t *testing.T) {
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+	peer.AllowedIPs = append(peer.AllowedIPs,
+		netip.MustParsePrefix("192.168.3.0/24"))
+
+	resp := monitoringplugin.NewResponse("test OK")
+	resp.SortOutputMessagesByStatus(false)
+	require.NoError(t, transferResponse(dump, "192.168.3.7", resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	assert.Contains(t, resp.GetInfo().RawOutput, "peer="+peer.Name())
+	assert.Contains(t, resp.GetInfo().RawOutput,
+		"route: 192.168.3.7 via 192.168.3.0/24")
+	assert.Contains(t, resp.GetInfo().RawOutput,
+		fmt.Sprintf(" 'rx'=%vb", peer.Rx))
+}
+
+func useTransferState(t *testing.T) string {
+	t.Helper()
+	transferState = filepath.Join(t.TempDir(), "transfer.json")
+	t.Cleanup(func() {
+		transferState = ""
+		transferRxWarn, transferRxCrit = 0, 0
+		transferTxWarn, transferTxCrit = 0, 0
+		transferQuota = 0
+		transferQuotaPeriod, transferQuotaDirection = "month", "total"
+		transferQuotaWarn, transferQuotaCrit = 80, 100
+	})
+	return transferState
+}
+
+func TestTransferResponse_rates(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "no previous state")
+	assert.NotContains(t, output, "'rx rate'")
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	st := state.Peer(peer)
+	require.NotNil(t, st)
+	assert.Equal(t, peer.Rx, st.Rx)
+	assert.Equal(t, peer.Tx, st.Tx)
+
+	st.Time = time.Now().Add(-100 * time.Second)
+	st.Rx -= 100 * 2048
+	st.Tx -= 100 * 1000
+	require.NoError(t, state.Save(name))
+
+	transferRxWarn, transferRxCrit = 1024, 4096
+	resp = monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.WARNING, resp.GetStatusCode())
+	output = resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "rx rate is outside of WARNING threshold")
+	assert.Contains(t, output, "rx rate: 2KiB/s")
+	assert.Contains(t, output, "tx rate: 1000B/s")
+	assert.Contains(t, output, " 'rx rate'=2048b;1024;4096;;")
+	assert.Contains(t, output, " 'tx rate'=1000b")
+
+	state, err = loadState(name)
+	require.NoError(t, err)
+	st = state.Peer(peer)
+	require.NotNil(t, st)
+	assert.Equal(t, peer.Rx, st.Rx)
+	assert.WithinDuration(t, time.Now(), st.Time, time.Minute)
+}
+
+func TestTransferResponse_ratesReset(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+	peer.Rx, peer.Tx = 100*500, 0
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	state.SetPeer(peer, &peerState{
+		Time: time.Now().Add(-100 * time.Second),
+		Rx:   1 << 30,
+		Tx:   1 << 30,
+	})
+	require.NoError(t, state.Save(name))
+
+	transferTxCrit = 1
+	resp := monitoringplugin.NewResponse("test OK")
+	require.NoError(t, transferResponse(dump, peer.Name(), resp))
+	assert.Equal(t, monitoringplugin.OK, resp.GetStatusCode())
+	output := resp.GetInfo().RawOutput
+	t.Log(output)
+	assert.Contains(t, output, "counters reset")
+	assert.Contains(t, output, " 'rx rate'=500b ")
+	assert.Contains(t, output, " 'tx rate'=0b;;1;;")
+}
+
+func TestTransferResponse_ratesErrors(t *testing.T) {
+	name := useTransferState(t)
+	dump := useDumpFile(t, "../wg/testdata/wg_show_dump.txt")
+	peer := &dump.Peers[1]
+
+	state, err := loadState(name)
+	require.NoError(t, err)
+	state.SetPeer(peer, &peerState{Time: time.Now().Add(time.Hour)})
+	require.NoError(t, state.Save(name))
+
+	resp := monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, transferResponse(dump, peer.Name(), resp),
+		"time of previous state in the future")
+
+	require.NoError(t, os.WriteFile(name, []byte("{"), 0o600))
+	resp = monitoringplugin.NewResponse("test OK")
+	require.ErrorContains(t, transferResponse(dump, peer.Name(), resp),
+		"parse state file")
+}
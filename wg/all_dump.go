@@ -0,0 +1,117 @@
+package wg
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+func NewAllDump(r io.Reader) (AllDump, error) {
+	var dump AllDump
+	return dump, dump.Parse(r)
+}
+
+// AllDump is parsed output of `wg show all dump`, where every line starts with
+// interface name.
+type AllDump struct {
+	Interfaces map[string]*Dump
+}
+
+func (self *AllDump) Parse(r io.Reader) error {
+	lines := csv.NewReader(r)
+	lines.Comma = '\t'
+	lines.ReuseRecord = true
+	lines.FieldsPerRecord = -1
+
+	self.Interfaces = make(map[string]*Dump)
+	for {
+		rec, err := lines.Read()
+		if errors.Is(err, io.EOF) {
+			if len(self.Interfaces) == 0 {
+				return fmt.Errorf("csv parse first line: %w", err)
+			}
+			break
+		} else if err != nil {
+			return fmt.Errorf("csv parse line: %w", err)
+		} else if err := self.parseRecord(rec); err != nil {
+			line, _ := lines.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return nil
+}
+
+func (self *AllDump) parseRecord(rec []string) error {
+	name := rec[0]
+	switch len(rec) {
+	case 5:
+		if _, ok := self.Interfaces[name]; ok {
+			return fmt.Errorf("duplicate interface record of %s", name)
+		}
+		dump := new(Dump)
+		if err := dump.parseInterface(rec[1:]); err != nil {
+			return fmt.Errorf("parse interface record of %s: %w", name, err)
+		}
+		self.Interfaces[name] = dump
+	case 9:
+		dump, ok := self.Interfaces[name]
+		if !ok {
+			return fmt.Errorf("peer record before interface record of %s", name)
+		}
+		peer, err := NewDumpPeer(rec[1:])
+		if err != nil {
+			return fmt.Errorf("parse peer record of %s: %w", name, err)
+		}
+		peer.Interface = name
+		dump.Peers = append(dump.Peers, peer)
+	default:
+		return fmt.Errorf("csv parse line of %s: %w", name, csv.ErrFieldCount)
+	}
+	return nil
+}
+
+// Add adds dump of interface with given name, like it was parsed from
+// `wg show all dump`.
+func (self *AllDump) Add(name string, dump Dump) error {
+	if self.Interfaces == nil {
+		self.Interfaces = make(map[string]*Dump)
+	} else if _, ok := self.Interfaces[name]; ok {
+		return fmt.Errorf("duplicate interface: %s", name)
+	}
+
+	for i := range dump.Peers {
+		dump.Peers[i].Interface = name
+	}
+	self.Interfaces[name] = &dump
+	return nil
+}
+
+// Names returns sorted names of all interfaces.
+func (self *AllDump) Names() []string {
+	return slices.Sorted(maps.Keys(self.Interfaces))
+}
+
+// Dump merges peers of interfaces with given names, or of all interfaces if no
+// names given, into single Dump. Interface fields of returned Dump are set only
+// if exactly one interface was selected, otherwise Multiple is set.
+func (self *AllDump) Dump(names ...string) (Dump, error) {
+	if len(names) == 0 {
+		names = self.Names()
+	}
+
+	var dump Dump
+	for _, name := range names {
+		d, ok := self.Interfaces[name]
+		if !ok {
+			return Dump{}, fmt.Errorf("interface not found: %s", name)
+		} else if len(names) == 1 {
+			dump, dump.Peers = *d, nil
+		}
+		dump.Peers = append(dump.Peers, d.Peers...)
+	}
+	dump.Multiple = len(names) != 1
+	return dump, nil
+}
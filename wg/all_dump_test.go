@@ -0,0 +1,126 @@
+package wg
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/wg_show_all_dump.txt
+var showAllDumpOutput []byte
+
+func TestAllDump_Parse(t *testing.T) {
+	dump, err := NewAllDump(bytes.NewBuffer(showAllDumpOutput))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wg0", "wg1"}, dump.Names())
+
+	wg0 := dump.Interfaces["wg0"]
+	require.NotNil(t, wg0)
+	assert.Equal(t, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", wg0.PublicKey)
+	assert.Equal(t, uint16(12345), wg0.ListenPort)
+	require.Len(t, wg0.Peers, 2)
+	assert.Equal(t, testDump.Peers[0].PublicKey, wg0.Peers[0].PublicKey)
+	assert.Equal(t, testDump.Peers[1].Rx, wg0.Peers[1].Rx)
+
+	wg1 := dump.Interfaces["wg1"]
+	require.NotNil(t, wg1)
+	assert.Equal(t, uint32(0x10), wg1.FwMark)
+	require.Len(t, wg1.Peers, 1)
+	assert.Equal(t, "10.0.1.2/32", wg1.Peers[0].Name())
+
+	for _, name := range dump.Names() {
+		for i := range dump.Interfaces[name].Peers {
+			peer := &dump.Interfaces[name].Peers[i]
+			assert.True(t, peer.Valid())
+			assert.Equal(t, name, peer.Interface)
+		}
+	}
+}
+
+func TestAllDump_Parse_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		errIs   error
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			errIs:   io.EOF,
+			wantErr: "csv parse first line",
+		},
+		{
+			name:    "single interface format",
+			input:   "A\tB\t0\toff",
+			errIs:   csv.ErrFieldCount,
+			wantErr: "csv parse line",
+		},
+		{
+			name:    "parse interface",
+			input:   "wg0\t(none)\t" + testKeyA + "\tC\tD",
+			errIs:   strconv.ErrSyntax,
+			wantErr: "parse interface record",
+		},
+		{
+			name:    "duplicate interface",
+			input:   "wg0\t" + testInterfaceLine + "wg0\t" + testInterfaceLine,
+			wantErr: "line 2: duplicate interface record",
+		},
+		{
+			name:    "peer before interface",
+			input:   "wg0\t1\t2\t3\t4\t0\t6\t7\toff",
+			wantErr: "peer record before interface record",
+		},
+		{
+			name: "parse peer",
+			input: "wg0\t" + testInterfaceLine +
+				"wg0\t" + testKeyB + "\t(none)\t(none)\t(none)\tX\t6\t7\t8",
+			errIs:   strconv.ErrSyntax,
+			wantErr: "line 2: parse peer record",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAllDump(bytes.NewBufferString(tt.input))
+			if tt.errIs != nil {
+				require.ErrorIs(t, err, tt.errIs)
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAllDump_Dump(t *testing.T) {
+	all, err := NewAllDump(bytes.NewBuffer(showAllDumpOutput))
+	require.NoError(t, err)
+
+	dump, err := all.Dump()
+	require.NoError(t, err)
+	assert.Empty(t, dump.PublicKey)
+	assert.True(t, dump.Multiple)
+	assert.Len(t, dump.Peers, 3)
+
+	dump, err = all.Dump("wg1")
+	require.NoError(t, err)
+	assert.Equal(t, all.Interfaces["wg1"].PublicKey, dump.PublicKey)
+	assert.False(t, dump.Multiple)
+	assert.Equal(t, all.Interfaces["wg1"].Peers, dump.Peers)
+	dump.Peers[0].Rx = 0
+	assert.NotZero(t, all.Interfaces["wg1"].Peers[0].Rx)
+
+	dump, err = all.Dump("wg1", "wg0")
+	require.NoError(t, err)
+	require.Len(t, dump.Peers, 3)
+	assert.Equal(t, "wg1", dump.Peers[0].Interface)
+	assert.Same(t, &dump.Peers[1], dump.Peer("10.0.0.2/32"))
+
+	_, err = all.Dump("wg2")
+	require.ErrorContains(t, err, "interface not found: wg2")
+}
@@ -0,0 +1,305 @@
+package wg
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is configuration of wireguard interface from a configuration file,
+// comparable with running interface by Diff.
+type Config struct {
+	PrivateKey string
+	ListenPort uint16
+	FwMark     uint32
+	// AutoFwMark is true, if fwmark isn't configured and is set by wg-quick(8)
+	// itself, because of Table=auto and a default route in allowed IPs.
+	AutoFwMark bool
+
+	Peers []ConfigPeer
+}
+
+type ConfigPeer struct {
+	PublicKey    string
+	PresharedKey string
+	// Endpoint is host:port, because it can be given by hostname.
+	Endpoint   string
+	AllowedIPs []netip.Prefix
+	Keepalive  time.Duration
+}
+
+// Peer returns configured peer with given public key or nil.
+func (self *Config) Peer(publicKey string) *ConfigPeer {
+	for i := range self.Peers {
+		if p := &self.Peers[i]; p.PublicKey == publicKey {
+			return p
+		}
+	}
+	return nil
+}
+
+// defaultRoute returns true if any peer has 0.0.0.0/0 or ::/0 in allowed IPs.
+func (self *Config) defaultRoute() bool {
+	for i := range self.Peers {
+		for _, prefix := range self.Peers[i].AllowedIPs {
+			if prefix.Bits() == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (self *Config) addPeer(p ConfigPeer, line int) error {
+	if p.PublicKey == "" {
+		return fmt.Errorf("line %d: peer without public key", line)
+	} else if self.Peer(p.PublicKey) != nil {
+		return fmt.Errorf("line %d: duplicate peer %s", line, p.PublicKey)
+	}
+	self.Peers = append(self.Peers, p)
+	return nil
+}
+
+type DriftField string
+
+const (
+	DriftListenPort   DriftField = "listen-port"
+	DriftFwMark       DriftField = "fwmark"
+	DriftPrivateKey   DriftField = "private-key"
+	DriftMissingPeer  DriftField = "missing-peer"
+	DriftAddedPeer    DriftField = "added-peer"
+	DriftAllowedIPs   DriftField = "allowed-ips"
+	DriftEndpoint     DriftField = "endpoint"
+	DriftKeepalive    DriftField = "persistent-keepalive"
+	DriftPresharedKey DriftField = "preshared-key"
+)
+
+// DriftFields are all fields compared by Diff.
+var DriftFields = [...]DriftField{
+	DriftListenPort,
+	DriftFwMark,
+	DriftPrivateKey,
+	DriftMissingPeer,
+	DriftAddedPeer,
+	DriftAllowedIPs,
+	DriftEndpoint,
+	DriftKeepalive,
+	DriftPresharedKey,
+}
+
+// Drift is a difference between configured and running field. Peer is public
+// key of the peer or empty for fields of interface.
+type Drift struct {
+	Field   DriftField
+	Peer    string
+	Config  string
+	Running string
+}
+
+// Diff compares configuration with running interface and returns all
+// differences. Listen port isn't compared if it's not configured, fwmark if it's
+// set automatically. Endpoints given by hostname and private key, absent from
+// dump, aren't compared either. Secret keys are never returned, only their
+// presence.
+func (self *Config) Diff(dump *Dump) []Drift {
+	var drifts []Drift
+	if self.ListenPort != 0 && self.ListenPort != dump.ListenPort {
+		drifts = append(drifts, Drift{
+			Field:   DriftListenPort,
+			Config:  strconv.FormatUint(uint64(self.ListenPort), 10),
+			Running: strconv.FormatUint(uint64(dump.ListenPort), 10),
+		})
+	}
+
+	if !self.AutoFwMark && self.FwMark != dump.FwMark {
+		drifts = append(drifts, Drift{
+			Field:   DriftFwMark,
+			Config:  FormatFwMark(self.FwMark),
+			Running: FormatFwMark(dump.FwMark),
+		})
+	}
+
+	if self.PrivateKey != "" && dump.PrivateKey != "" &&
+		self.PrivateKey != dump.PrivateKey {
+		drifts = append(drifts, Drift{
+			Field:   DriftPrivateKey,
+			Config:  dumpHidden,
+			Running: dumpHidden,
+		})
+	}
+
+	for i := range self.Peers {
+		p := &self.Peers[i]
+		running := findDumpPeer(dump, p.PublicKey)
+		if running == nil {
+			drifts = append(drifts, Drift{
+				Field:   DriftMissingPeer,
+				Peer:    p.PublicKey,
+				Config:  formatPrefixes(p.AllowedIPs),
+				Running: dumpNone,
+			})
+			continue
+		}
+		drifts = append(drifts, p.diff(running)...)
+	}
+
+	for i := range dump.Peers {
+		p := &dump.Peers[i]
+		if self.Peer(p.PublicKey) == nil {
+			drifts = append(drifts, Drift{
+				Field:   DriftAddedPeer,
+				Peer:    p.PublicKey,
+				Config:  dumpNone,
+				Running: formatPrefixes(p.AllowedIPs),
+			})
+		}
+	}
+	return drifts
+}
+
+func (self *ConfigPeer) diff(running *DumpPeer) []Drift {
+	var drifts []Drift
+	add := func(field DriftField, config, running string) {
+		drifts = append(drifts, Drift{
+			Field:   field,
+			Peer:    self.PublicKey,
+			Config:  config,
+			Running: running,
+		})
+	}
+
+	config, runningIPs := formatPrefixes(self.AllowedIPs),
+		formatPrefixes(running.AllowedIPs)
+	if config != runningIPs {
+		add(DriftAllowedIPs, config, runningIPs)
+	}
+
+	// Endpoints given by hostname can't be compared without resolving.
+	ep, err := netip.ParseAddrPort(self.Endpoint)
+	if err == nil && netip.AddrPortFrom(ep.Addr().Unmap(), ep.Port()) !=
+		netip.AddrPortFrom(running.Endpoint.Addr().Unmap(), running.Endpoint.Port()) {
+		add(DriftEndpoint, self.Endpoint, formatEndpoint(running.Endpoint))
+	}
+
+	if self.Keepalive != running.Keepalive {
+		add(DriftKeepalive, formatKeepalive(self.Keepalive),
+			formatKeepalive(running.Keepalive))
+	}
+
+	switch {
+	case (self.PresharedKey == "") != (running.PresharedKey == ""):
+		add(DriftPresharedKey, hideKeyOrNone(self.PresharedKey),
+			hideKeyOrNone(running.PresharedKey))
+	case self.PresharedKey != running.PresharedKey:
+		add(DriftPresharedKey, dumpHidden, dumpHidden)
+	}
+	return drifts
+}
+
+func findDumpPeer(dump *Dump, publicKey string) *DumpPeer {
+	for i := range dump.Peers {
+		if p := &dump.Peers[i]; p.PublicKey == publicKey {
+			return p
+		}
+	}
+	return nil
+}
+
+// formatPrefixes returns sorted and masked prefixes, separated by comma, or
+// (none).
+func formatPrefixes(prefixes []netip.Prefix) string {
+	if len(prefixes) == 0 {
+		return dumpNone
+	}
+
+	s := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		s[i] = prefix.Masked().String()
+	}
+	slices.Sort(s)
+	return strings.Join(slices.Compact(s), ", ")
+}
+
+func formatEndpoint(ep netip.AddrPort) string {
+	if !ep.IsValid() {
+		return dumpNone
+	}
+	return ep.String()
+}
+
+// FormatFwMark returns fwmark in hex, like wg(8) outputs it, or off.
+func FormatFwMark(fwMark uint32) string {
+	if fwMark == 0 {
+		return dumpOff
+	}
+	return "0x" + strconv.FormatUint(uint64(fwMark), 16)
+}
+
+func formatKeepalive(d time.Duration) string {
+	if d == 0 {
+		return dumpOff
+	}
+	return d.String()
+}
+
+func hideKeyOrNone(key string) string {
+	if key == "" {
+		return dumpNone
+	}
+	return dumpHidden
+}
+
+func parseConfigPort(s string) (uint16, error) {
+	port, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("failed parse port number %q: %w", s, err)
+	}
+	return uint16(port), nil
+}
+
+func parseConfigFwMark(s string) (uint32, error) {
+	if s == dumpOff {
+		return 0, nil
+	}
+	fwMark, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("failed parse fwmark %q: %w", s, err)
+	}
+	return uint32(fwMark), nil
+}
+
+func parseConfigKeepalive(s string) (time.Duration, error) {
+	if s == dumpOff {
+		return 0, nil
+	}
+	secs, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("failed parse persistent keepalive %q: %w", s, err)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// parseConfigAllowedIPs parses list of prefixes, separated by comma or spaces.
+// Address without mask is a single address prefix.
+func parseConfigAllowedIPs(s string) ([]netip.Prefix, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	prefixes := make([]netip.Prefix, 0, len(fields))
+	for _, field := range fields {
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			addr, err2 := netip.ParseAddr(field)
+			if err2 != nil {
+				return nil, fmt.Errorf("failed parse allowed ip %q: %w", field, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,273 @@
+package wg
+
+import (
+	"bytes"
+	_ "embed"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/wg0.conf
+var quickConfig []byte
+
+func TestNewQuickConfig(t *testing.T) {
+	cfg, err := NewQuickConfig(bytes.NewReader(quickConfig))
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		PrivateKey: "ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZA=",
+		ListenPort: 12345,
+		Peers: []ConfigPeer{
+			{
+				PublicKey:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+				Endpoint:   "10.0.0.1:54321",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+				Keepalive:  15 * time.Second,
+			},
+			{
+				PublicKey:  "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+				Endpoint:   "peer3.example.com:54322",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32")},
+			},
+			{
+				PublicKey:  "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA=",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.4/32")},
+			},
+			{
+				PublicKey:  "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA=",
+				AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.5/32")},
+			},
+		},
+	}, cfg)
+}
+
+func TestNewQuickConfig_keys(t *testing.T) {
+	cfg, err := NewQuickConfig(strings.NewReader(`
+[interface]
+FwMark = 0x10
+[Peer]
+publickey = BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+PresharedKey = CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=
+AllowedIPs = 10.0.0.2/32, fd00::2/128
+AllowedIPs = 192.168.0.0/16
+PersistentKeepalive = off
+`))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x10), cfg.FwMark)
+	require.Len(t, cfg.Peers, 1)
+	assert.Equal(t, "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+		cfg.Peers[0].PresharedKey)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.2/32"),
+		netip.MustParsePrefix("fd00::2/128"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+	}, cfg.Peers[0].AllowedIPs)
+	assert.Zero(t, cfg.Peers[0].Keepalive)
+}
+
+func TestNewQuickConfig_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "without interface",
+			input: "[Peer]\nPublicKey = BBBB\n",
+			err:   "no [Interface] section",
+		},
+		{
+			name:  "duplicate interface",
+			input: "[Interface]\n[Interface]\n",
+			err:   "line 2: duplicate [Interface] section",
+		},
+		{
+			name:  "unknown section",
+			input: "[Interface]\n[Foo]\n",
+			err:   "line 2: unknown section [Foo]",
+		},
+		{
+			name:  "unknown key",
+			input: "[Interface]\nFoo = bar\n",
+			err:   "line 2: unknown key \"Foo\"",
+		},
+		{
+			name:  "invalid listen port",
+			input: "[Interface]\nListenPort = 100000\n",
+			err:   "line 2: failed parse port number",
+		},
+		{
+			name:  "invalid fwmark",
+			input: "[Interface]\nFwMark = foo\n",
+			err:   "line 2: failed parse fwmark",
+		},
+		{
+			name:  "peer without public key",
+			input: "[Interface]\n[Peer]\nAllowedIPs = 10.0.0.2/32\n",
+			err:   "line 2: peer without public key",
+		},
+		{
+			name:  "duplicate peer",
+			input: "[Interface]\n[Peer]\nPublicKey = BBBB\n[Peer]\nPublicKey = BBBB\n",
+			err:   "line 4: duplicate peer BBBB",
+		},
+		{
+			name:  "invalid allowed ips",
+			input: "[Interface]\n[Peer]\nAllowedIPs = foo\n",
+			err:   "line 3: failed parse allowed ip \"foo\"",
+		},
+		{
+			name:  "invalid keepalive",
+			input: "[Interface]\n[Peer]\nPersistentKeepalive = foo\n",
+			err:   "line 3: failed parse persistent keepalive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewQuickConfig(strings.NewReader(tt.input))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestConfig_Diff(t *testing.T) {
+	cfg, err := NewQuickConfig(bytes.NewReader(quickConfig))
+	require.NoError(t, err)
+	dump, err := NewDump(bytes.NewReader(showDumpOutput))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Diff(&dump))
+
+	dump.ListenPort = 51820
+	dump.FwMark = 0x10
+	dump.PrivateKey = "YYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYYA="
+	dump.Peers[0].AllowedIPs = append(dump.Peers[0].AllowedIPs,
+		netip.MustParsePrefix("192.168.0.0/16"))
+	dump.Peers[0].Endpoint = netip.MustParseAddrPort("10.0.0.10:54321")
+	dump.Peers[0].Keepalive = 0
+	dump.Peers[1].Endpoint = netip.MustParseAddrPort("10.0.0.10:54322")
+	dump.Peers[1].PresharedKey = "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXA="
+	dump.Peers[3].PublicKey = "WWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWA="
+
+	assert.Equal(t, []Drift{
+		{Field: DriftListenPort, Config: "12345", Running: "51820"},
+		{Field: DriftFwMark, Config: "off", Running: "0x10"},
+		{Field: DriftPrivateKey, Config: "(hidden)", Running: "(hidden)"},
+		{
+			Field:   DriftAllowedIPs,
+			Peer:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+			Config:  "10.0.0.2/32",
+			Running: "10.0.0.2/32, 192.168.0.0/16",
+		},
+		{
+			Field:   DriftEndpoint,
+			Peer:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+			Config:  "10.0.0.1:54321",
+			Running: "10.0.0.10:54321",
+		},
+		{
+			Field:   DriftKeepalive,
+			Peer:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+			Config:  "15s",
+			Running: "off",
+		},
+		{
+			Field:   DriftPresharedKey,
+			Peer:    "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+			Config:  "(none)",
+			Running: "(hidden)",
+		},
+		{
+			Field:   DriftMissingPeer,
+			Peer:    "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA=",
+			Config:  "10.0.0.5/32",
+			Running: "(none)",
+		},
+		{
+			Field:   DriftAddedPeer,
+			Peer:    "WWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWWA=",
+			Config:  "(none)",
+			Running: "10.0.0.5/32",
+		},
+	}, cfg.Diff(&dump))
+}
+
+func TestConfig_Diff_autoFwMark(t *testing.T) {
+	dump := Dump{FwMark: 0xca6c, Peers: []DumpPeer{{
+		PublicKey:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		AllowedIPs: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+	}}}
+	peer := `
+[Peer]
+PublicKey = BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+AllowedIPs = 0.0.0.0/0
+`
+
+	tests := []struct {
+		name  string
+		iface string
+		auto  bool
+	}{
+		{name: "default table", iface: "[Interface]\n", auto: true},
+		{name: "table auto", iface: "[Interface]\nTable = auto\n", auto: true},
+		{name: "table off", iface: "[Interface]\nTable = off\n"},
+		{name: "table id", iface: "[Interface]\nTable = 1234\n"},
+		{name: "fwmark", iface: "[Interface]\nFwMark = 0x10\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewQuickConfig(strings.NewReader(tt.iface + peer))
+			require.NoError(t, err)
+			assert.Equal(t, tt.auto, cfg.AutoFwMark)
+			drifts := cfg.Diff(&dump)
+			if tt.auto {
+				assert.Empty(t, drifts)
+			} else {
+				require.Len(t, drifts, 1)
+				assert.Equal(t, DriftFwMark, drifts[0].Field)
+			}
+		})
+	}
+
+	cfg, err := NewQuickConfig(strings.NewReader(`
+[Interface]
+[Peer]
+PublicKey = BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+AllowedIPs = 10.0.0.0/8
+`))
+	require.NoError(t, err)
+	assert.False(t, cfg.AutoFwMark)
+}
+
+func TestConfig_Diff_presharedKey(t *testing.T) {
+	cfg := Config{Peers: []ConfigPeer{{
+		PublicKey:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		PresharedKey: "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+	}}}
+	dump := Dump{Peers: []DumpPeer{{
+		PublicKey:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		PresharedKey: "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+	}}}
+	assert.Empty(t, cfg.Diff(&dump))
+
+	dump.Peers[0].PresharedKey = "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA="
+	assert.Equal(t, []Drift{{
+		Field:   DriftPresharedKey,
+		Peer:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		Config:  "(hidden)",
+		Running: "(hidden)",
+	}}, cfg.Diff(&dump))
+
+	dump.Peers[0].PresharedKey = ""
+	assert.Equal(t, []Drift{{
+		Field:   DriftPresharedKey,
+		Peer:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		Config:  "(hidden)",
+		Running: "(none)",
+	}}, cfg.Diff(&dump))
+}
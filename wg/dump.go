@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,10 @@ type Dump struct {
 	FwMark     uint32
 
 	Peers []DumpPeer
+
+	// Multiple is true, if peers of multiple interfaces were merged into this
+	// Dump by AllDump.Dump. Interface fields are empty in this case.
+	Multiple bool
 }
 
 func (self *Dump) Parse(r io.Reader) error {
@@ -39,9 +44,9 @@ func (self *Dump) Parse(r io.Reader) error {
 	lines.FieldsPerRecord = 4
 	rec, err := lines.Read()
 	if err != nil {
-		return fmt.Errorf("csv parse first line %v: %w", rec, err)
+		return fmt.Errorf("csv parse first line: %w", err)
 	} else if err := self.parseInterface(rec); err != nil {
-		return fmt.Errorf("parse interface record %v: %w", rec, err)
+		return fmt.Errorf("line 1: parse interface record: %w", err)
 	}
 
 	lines.FieldsPerRecord = 8
@@ -50,11 +55,12 @@ func (self *Dump) Parse(r io.Reader) error {
 		if errors.Is(err, io.EOF) {
 			break
 		} else if err != nil {
-			return fmt.Errorf("csv parse peer line %v: %w", rec, err)
+			return fmt.Errorf("csv parse peer line: %w", err)
 		}
 		peer, err := NewDumpPeer(rec)
 		if err != nil {
-			return fmt.Errorf("parse peer record %v: %w", rec, err)
+			line, _ := lines.FieldPos(0)
+			return fmt.Errorf("line %d: parse peer record: %w", line, err)
 		}
 		self.Peers = append(self.Peers, peer)
 	}
@@ -63,9 +69,19 @@ func (self *Dump) Parse(r io.Reader) error {
 
 func (self *Dump) parseInterface(rec []string) error {
 	if rec[0] != dumpNone {
+		if err := validKey(rec[0]); err != nil {
+			return fmt.Errorf("failed parse private-key: %w", err)
+		}
 		self.PrivateKey = rec[0]
 	}
-	self.PublicKey = rec[1]
+
+	if rec[1] != dumpNone {
+		if err := validKey(rec[1]); err != nil {
+			return fmt.Errorf("failed parse public-key: %w", err)
+		}
+		self.PublicKey = rec[1]
+	}
+
 	if err := self.parseListenPort(rec[2]); err != nil {
 		return err
 	} else if err := self.parseFwMark(rec[3]); err != nil {
@@ -101,10 +117,19 @@ func (self *Dump) OldestHandshake(excludePeers ...string) *DumpPeer {
 		exclude[s] = struct{}{}
 	}
 
+	return self.OldestHandshakeFunc(func(p *DumpPeer) bool {
+		_, ok := exclude[p.Name()]
+		return !ok
+	})
+}
+
+// OldestHandshakeFunc returns peer with the oldest latest handshake from peers,
+// for which fn returns true.
+func (self *Dump) OldestHandshakeFunc(fn func(p *DumpPeer) bool) *DumpPeer {
 	var oldestPeer *DumpPeer
 	for i := range self.Peers {
 		p := &self.Peers[i]
-		if _, ok := exclude[p.Name()]; ok {
+		if !fn(p) {
 			continue
 		}
 		if oldestPeer == nil || p.HandshakeBefore(oldestPeer) {
@@ -138,24 +163,39 @@ func NewDumpPeer(rec []string) (DumpPeer, error) {
 type DumpPeer struct {
 	PublicKey       string
 	PresharedKey    string
-	Endpoint        string
-	AllowedIPs      []string
+	Endpoint        netip.AddrPort
+	AllowedIPs      []netip.Prefix
 	LatestHandshake time.Time
 	Rx              uint64
 	Tx              uint64
 	Keepalive       time.Duration
 
+	// Interface is name of wireguard interface of this peer. It's set only for
+	// peers parsed from `wg show all dump`.
+	Interface string
+
 	valid bool
 }
 
 func (self *DumpPeer) Parse(rec []string) error {
+	if err := validKey(rec[0]); err != nil {
+		return fmt.Errorf("failed parse public-key: %w", err)
+	}
 	self.PublicKey = rec[0]
+
 	if rec[1] != dumpNone {
+		if err := validKey(rec[1]); err != nil {
+			return fmt.Errorf("failed parse preshared-key: %w", err)
+		}
 		self.PresharedKey = rec[1]
 	}
-	self.Endpoint = rec[2]
-	self.AllowedIPs = strings.Split(rec[3], ",")
-	if err := self.parseLatestHanshake(rec[4]); err != nil {
+
+	if err := self.parseEndpoint(rec[2]); err != nil {
+		return err
+	}
+	if err := self.parseAllowedIPs(rec[3]); err != nil {
+		return err
+	} else if err := self.parseLatestHanshake(rec[4]); err != nil {
 		return err
 	} else if err := self.parseRxTx(rec[5], rec[6]); err != nil {
 		return err
@@ -163,6 +203,42 @@ func (self *DumpPeer) Parse(rec []string) error {
 	return self.parseKeepalive(rec[7])
 }
 
+func (self *DumpPeer) parseEndpoint(s string) error {
+	if s == dumpNone {
+		self.Endpoint = netip.AddrPort{}
+		return nil
+	}
+	endpoint, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return fmt.Errorf("failed parse endpoint %q: %w", s, err)
+	}
+	self.Endpoint = endpoint
+	return nil
+}
+
+func (self *DumpPeer) parseAllowedIPs(s string) error {
+	self.AllowedIPs = nil
+	if s == dumpNone {
+		return nil
+	}
+
+	for cidr := range strings.SplitSeq(s, ",") {
+		if err := self.parseAllowedIP(cidr); err != nil {
+			return fmt.Errorf("failed parse allowed-ips %q: %w", s, err)
+		}
+	}
+	return nil
+}
+
+func (self *DumpPeer) parseAllowedIP(s string) error {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return fmt.Errorf("parse allowed ip: %w", err)
+	}
+	self.AllowedIPs = append(self.AllowedIPs, prefix)
+	return nil
+}
+
 func (self *DumpPeer) parseLatestHanshake(s string) error {
 	secs, err := strconv.ParseUint(s, 10, 64)
 	if err != nil {
@@ -208,21 +284,26 @@ func (self *DumpPeer) HandshakeBefore(p *DumpPeer) bool {
 	return self.LatestHandshake.Before(p.LatestHandshake)
 }
 
+// Name returns first allowed IP of peer or its public key, if peer has no
+// allowed IPs.
 func (self *DumpPeer) Name() string {
-	return self.AllowedIPs[0]
+	if len(self.AllowedIPs) == 0 {
+		return self.PublicKey
+	}
+	return self.AllowedIPs[0].String()
 }
 
 func (self *DumpPeer) ResolvedName() (string, error) {
-	cidr := self.AllowedIPs[0]
-	ip, _, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return "", fmt.Errorf("parse %q: %w", cidr, err)
+	if len(self.AllowedIPs) == 0 {
+		return self.PublicKey, nil
 	}
 
-	hostname, err := lookupAddr(ip.String())
+	cidr := self.AllowedIPs[0].String()
+	ip := self.AllowedIPs[0].Addr().String()
+	hostname, err := lookupAddr(ip)
 	if err != nil {
 		return "", fmt.Errorf("resolving %q from %q: %w", ip, cidr, err)
-	} else if hostname == ip.String() {
+	} else if hostname == ip {
 		return cidr, nil
 	}
 	// ip/mask (hostname)
@@ -245,18 +326,24 @@ func lookupAddr(name string) (string, error) {
 	return hostname, nil
 }
 
+// HasEndpoint returns true if peer has endpoint.
+func (self *DumpPeer) HasEndpoint() bool {
+	return self.Endpoint.IsValid()
+}
+
 func (self *DumpPeer) EndpointName() (string, error) {
-	ip, _, found := strings.Cut(self.Endpoint, ":")
-	if !found {
-		return self.Endpoint, nil
+	if !self.HasEndpoint() {
+		return dumpNone, nil
 	}
 
+	endpoint := self.Endpoint.String()
+	ip := self.Endpoint.Addr().Unmap().WithZone("").String()
 	hostname, err := lookupAddr(ip)
 	if err != nil {
-		return "", fmt.Errorf("resolving %q from %q: %w", ip, self.Endpoint, err)
+		return "", fmt.Errorf("resolving %q from %q: %w", ip, endpoint, err)
 	} else if hostname == ip {
-		return self.Endpoint, nil
+		return endpoint, nil
 	}
-	// ip:port (hostname)
-	return self.Endpoint + " (" + hostname + ")", nil
+	// ip:port (hostname) or [ipv6]:port (hostname)
+	return endpoint + " (" + hostname + ")", nil
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/csv"
 	"io"
 	"net"
+	"net/netip"
 	"strconv"
 	"testing"
 	"time"
@@ -22,15 +23,15 @@ var showDumpZeroHandshake []byte
 
 var testDump = Dump{
 	PrivateKey: "",
-	PublicKey:  "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA",
+	PublicKey:  "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
 	ListenPort: 12345,
 	FwMark:     0,
 	Peers: []DumpPeer{
 		{
-			PublicKey:       "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB",
+			PublicKey:       "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
 			PresharedKey:    "",
-			Endpoint:        "10.0.0.1:54321",
-			AllowedIPs:      []string{"10.0.0.2/32"},
+			Endpoint:        netip.MustParseAddrPort("10.0.0.1:54321"),
+			AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
 			LatestHandshake: time.Unix(1709565849, 0),
 			Rx:              293787123,
 			Tx:              2098018008,
@@ -38,10 +39,10 @@ var testDump = Dump{
 			valid:           true,
 		},
 		{
-			PublicKey:       "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC",
+			PublicKey:       "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
 			PresharedKey:    "",
-			Endpoint:        "10.0.0.1:54322",
-			AllowedIPs:      []string{"10.0.0.3/32"},
+			Endpoint:        netip.MustParseAddrPort("10.0.0.1:54322"),
+			AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32")},
 			LatestHandshake: time.Unix(1709565798, 0),
 			Rx:              984267560,
 			Tx:              3834155220,
@@ -49,10 +50,10 @@ var testDump = Dump{
 			valid:           true,
 		},
 		{
-			PublicKey:       "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDD",
+			PublicKey:       "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA=",
 			PresharedKey:    "",
-			Endpoint:        "10.0.0.1:54323",
-			AllowedIPs:      []string{"10.0.0.4/32"},
+			Endpoint:        netip.MustParseAddrPort("10.0.0.1:54323"),
+			AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.4/32")},
 			LatestHandshake: time.Unix(1709565713, 0),
 			Rx:              10672758695,
 			Tx:              338641384756,
@@ -60,10 +61,10 @@ var testDump = Dump{
 			valid:           true,
 		},
 		{
-			PublicKey:       "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEE",
+			PublicKey:       "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA=",
 			PresharedKey:    "",
-			Endpoint:        "10.0.0.1:54324",
-			AllowedIPs:      []string{"10.0.0.5/32"},
+			Endpoint:        netip.MustParseAddrPort("10.0.0.1:54324"),
+			AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.5/32")},
 			LatestHandshake: time.Unix(1709565894, 0),
 			Rx:              3803572656,
 			Tx:              61671294044,
@@ -86,7 +87,7 @@ func TestDump_Parse(t *testing.T) {
 	for i := range dump.Peers {
 		peer := &dump.Peers[i]
 		assert.True(t, peer.Valid())
-		assert.Equal(t, peer.AllowedIPs[0], peer.Name())
+		assert.Equal(t, peer.AllowedIPs[0].String(), peer.Name())
 		assert.Same(t, peer, dump.Peer(peer.Name()))
 	}
 
@@ -100,8 +101,22 @@ func TestDump_Parse(t *testing.T) {
 	t.Log("2nd oldest peer:", peer2.Name(), peer2.LatestHandshake)
 	assert.NotSame(t, peer, peer2)
 	assert.Same(t, &dump.Peers[1], peer2)
+
+	assert.Nil(t, dump.OldestHandshakeFunc(func(p *DumpPeer) bool {
+		return false
+	}))
+	assert.Same(t, &dump.Peers[0], dump.OldestHandshakeFunc(
+		func(p *DumpPeer) bool { return p == &dump.Peers[0] }))
 }
 
+const (
+	testKeyA = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	testKeyB = "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="
+	testKeyC = "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA="
+
+	testInterfaceLine = "(none)\t" + testKeyA + "\t0\toff\n"
+)
+
 func TestDump_Parse_readEOF(t *testing.T) {
 	b := bytes.NewBufferString("")
 	_, err := NewDump(b)
@@ -110,29 +125,69 @@ func TestDump_Parse_readEOF(t *testing.T) {
 }
 
 func TestDump_Parse_parseInterface_Err(t *testing.T) {
-	b := bytes.NewBufferString("A\tB\tC\tD")
+	b := bytes.NewBufferString(testKeyA + "\t" + testKeyB + "\tC\tD")
 	_, err := NewDump(b)
 	require.ErrorIs(t, err, strconv.ErrSyntax)
 	require.ErrorContains(t, err, "parse interface record")
 }
 
 func TestDump_Parse_parsePeerLineErr(t *testing.T) {
-	b := bytes.NewBufferString("A\tB\t0\toff\nE")
+	b := bytes.NewBufferString(testInterfaceLine + "E")
 	_, err := NewDump(b)
 	require.ErrorIs(t, err, csv.ErrFieldCount)
 }
 
 func TestDump_Parse_newDumpPeer_Err(t *testing.T) {
-	b := bytes.NewBufferString("A\tB\t0\toff\n1\t2\t3\t4\tX\t6\t7\t8")
+	b := bytes.NewBufferString(testInterfaceLine +
+		testKeyC + "\t(none)\t(none)\t(none)\tX\t6\t7\t8")
 	_, err := NewDump(b)
 	require.ErrorIs(t, err, strconv.ErrSyntax)
-	require.ErrorContains(t, err, "parse peer record")
+	require.ErrorContains(t, err, "line 2: parse peer record")
 	require.ErrorContains(t, err, "failed parse latest-handshake")
 }
 
+func TestDump_Parse_invalidKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "private key",
+			input: "foobar\t" + testKeyA + "\t0\toff\n",
+			err:   "line 1: parse interface record",
+		},
+		{
+			name:  "public key of interface",
+			input: "(none)\t" + testKeyA[1:] + "\t0\toff\n",
+			err:   "failed parse public-key",
+		},
+		{
+			name: "public key of peer",
+			input: testInterfaceLine +
+				testKeyB + "\t(none)\t(none)\t10.0.0.2/32\t0\t0\t0\toff\n" +
+				testKeyC[:40] + "\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n",
+			err: "line 3: parse peer record",
+		},
+		{
+			name: "preshared key",
+			input: testInterfaceLine +
+				testKeyB + "\t" + testKeyC + "C\t(none)\t10.0.0.2/32\t0\t0\t0\toff\n",
+			err: "failed parse preshared-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDump(bytes.NewBufferString(tt.input))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
 func TestDump_parseInterface_parseFwMark_Err(t *testing.T) {
 	var dump Dump
-	err := dump.parseInterface([]string{"A", "B", "0", "C"})
+	err := dump.parseInterface([]string{testKeyA, testKeyB, "0", "C"})
 	require.ErrorIs(t, err, strconv.ErrSyntax)
 }
 
@@ -167,11 +222,15 @@ func TestDumpPeer_emptyNotValid(t *testing.T) {
 
 func TestDumpPeer_Parse_parseRxTx_Err(t *testing.T) {
 	var peer DumpPeer
-	err := peer.Parse([]string{"", "", "", "", "0", "RX", "TX"})
+	err := peer.Parse([]string{
+		testKeyC, "(none)", "(none)", "(none)", "0", "RX", "TX",
+	})
 	require.ErrorIs(t, err, strconv.ErrSyntax)
 	require.ErrorContains(t, err, "failed parse transfer-rx")
 
-	err = peer.Parse([]string{"", "", "", "", "0", "0", "TX"})
+	err = peer.Parse([]string{
+		testKeyC, "(none)", "(none)", "(none)", "0", "0", "TX",
+	})
 	require.ErrorIs(t, err, strconv.ErrSyntax)
 	require.ErrorContains(t, err, "failed parse transfer-tx")
 }
@@ -231,73 +290,215 @@ func TestLookupAddr(t *testing.T) {
 
 func TestDumpPeer_ResolvedName(t *testing.T) {
 	tests := []struct {
-		name  string
-		peer  string
-		errAs any
+		name     string
+		peer     string
+		expected string
 	}{
 		{
 			name: "localhost",
 			peer: "127.0.0.1/32",
 		},
-		{
-			name:  "invalid CIDR",
-			peer:  "127.0.0.1",
-			errAs: &net.ParseError{},
-		},
 		{
 			name: "no such host",
 			peer: "127.0.0.2/32",
 		},
+		{
+			name:     "no allowed ips",
+			expected: "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			peer := DumpPeer{AllowedIPs: []string{tt.peer}}
+			peer := DumpPeer{PublicKey: "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA="}
+			if tt.peer != "" {
+				peer.AllowedIPs = []netip.Prefix{netip.MustParsePrefix(tt.peer)}
+			}
 			hostname, err := peer.ResolvedName()
-			if tt.errAs != nil {
-				require.ErrorAs(t, err, &tt.errAs)
-			} else {
-				require.NoError(t, err)
-				t.Log(hostname)
+			require.NoError(t, err)
+			t.Log(hostname)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, hostname)
 			}
 		})
 	}
 }
 
+func TestDumpPeer_parseAllowedIPs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []netip.Prefix
+		wantErr  string
+	}{
+		{
+			name:     "single",
+			input:    "10.0.0.2/32",
+			expected: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+		},
+		{
+			name:  "multiple",
+			input: "10.0.0.2/32,192.168.0.0/24,fd00::2/128",
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("192.168.0.0/24"),
+				netip.MustParsePrefix("fd00::2/128"),
+			},
+		},
+		{
+			name:  "none",
+			input: "(none)",
+		},
+		{
+			name:    "without mask",
+			input:   "10.0.0.2/32,10.0.0.3",
+			wantErr: `failed parse allowed-ips "10.0.0.2/32,10.0.0.3"`,
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: "failed parse allowed-ips",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var peer DumpPeer
+			err := peer.parseAllowedIPs(tt.input)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, peer.AllowedIPs)
+		})
+	}
+}
+
+func TestDump_Parse_noneAllowedIPs(t *testing.T) {
+	b := bytes.NewBufferString(testInterfaceLine +
+		testKeyC + "\t(none)\t(none)\t(none)\t0\t0\t0\toff")
+	dump, err := NewDump(b)
+	require.NoError(t, err)
+	require.Len(t, dump.Peers, 1)
+
+	peer := &dump.Peers[0]
+	assert.Empty(t, peer.AllowedIPs)
+	assert.Equal(t, testKeyC, peer.Name())
+	assert.Same(t, peer, dump.Peer(testKeyC))
+	assert.Nil(t, dump.Peer("(none)"))
+}
+
+func TestDumpPeer_parseEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		expected netip.AddrPort
+		wantErr  string
+	}{
+		{
+			name:     "ipv4",
+			endpoint: "10.0.0.1:54321",
+			expected: netip.MustParseAddrPort("10.0.0.1:54321"),
+		},
+		{
+			name:     "ipv6",
+			endpoint: "[2001:db8::1]:51820",
+			expected: netip.MustParseAddrPort("[2001:db8::1]:51820"),
+		},
+		{
+			name:     "none",
+			endpoint: "(none)",
+		},
+		{
+			name:     "without port",
+			endpoint: "10.0.0.1",
+			wantErr:  "failed parse endpoint",
+		},
+		{
+			name:     "hostname",
+			endpoint: "localhost:54321",
+			wantErr:  "failed parse endpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var peer DumpPeer
+			err := peer.parseEndpoint(tt.endpoint)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, peer.Endpoint)
+			assert.Equal(t, tt.expected.IsValid(), peer.HasEndpoint())
+		})
+	}
+}
+
+func TestDump_Parse_ipv6Endpoint(t *testing.T) {
+	b := bytes.NewBufferString(testInterfaceLine +
+		testKeyB + "\t(none)\t[2001:db8::1]:51820\t10.0.0.2/32\t0\t0\t0\toff\n" +
+		testKeyC + "\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff")
+	dump, err := NewDump(b)
+	require.NoError(t, err)
+	require.Len(t, dump.Peers, 2)
+	assert.Equal(t, netip.MustParseAddrPort("[2001:db8::1]:51820"),
+		dump.Peers[0].Endpoint)
+	assert.False(t, dump.Peers[1].HasEndpoint())
+
+	b = bytes.NewBufferString(testInterfaceLine +
+		testKeyB + "\t(none)\t2001:db8::1:51820\t10.0.0.2/32\t0\t0\t0\toff")
+	_, err = NewDump(b)
+	require.ErrorContains(t, err, "failed parse endpoint")
+}
+
 func TestDumpPeer_EndpointName(t *testing.T) {
 	tests := []struct {
 		name     string
 		endpoint string
-		errAs    any
+		expected string
 	}{
 		{
 			name:     "localhost",
 			endpoint: "127.0.0.1:12345",
 		},
 		{
-			name:     "localhost without port",
-			endpoint: "127.0.0.1",
+			name:     "localhost ipv6",
+			endpoint: "[::1]:12345",
 		},
 		{
 			name:     "no such host",
 			endpoint: "127.0.0.2:12345",
 		},
 		{
-			name:     "unrecognized address",
-			endpoint: "127.0.0:12345",
-			errAs:    &net.DNSError{},
+			name:     "no such host ipv6",
+			endpoint: "[2001:db8::1]:51820",
+			expected: "[2001:db8::1]:51820",
+		},
+		{
+			name:     "ipv4-mapped ipv6",
+			endpoint: "[::ffff:255.255.255.255]:12345",
+			expected: "[::ffff:255.255.255.255]:12345",
+		},
+		{
+			name:     "none",
+			expected: "(none)",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			peer := DumpPeer{Endpoint: tt.endpoint}
+			var peer DumpPeer
+			if tt.endpoint != "" {
+				peer.Endpoint = netip.MustParseAddrPort(tt.endpoint)
+			}
 			hostname, err := peer.EndpointName()
-			if tt.errAs != nil {
-				require.ErrorAs(t, err, &tt.errAs)
-			} else {
-				require.NoError(t, err)
-				t.Log(hostname)
+			require.NoError(t, err)
+			t.Log(hostname)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, hostname)
 			}
 		})
 	}
@@ -0,0 +1,67 @@
+package wg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// iniSection is a section of INI file, like [Interface] or [Peer], with its
+// keys in the same order as in the file.
+type iniSection struct {
+	Name string
+	Line int
+	Keys []iniKey
+}
+
+type iniKey struct {
+	Name  string
+	Value string
+	Line  int
+}
+
+// parseINI splits INI file, like configuration file of wg-quick(8) or
+// systemd.netdev(5), into sections. Comments start with # or ; and keys
+// before the first section are not allowed.
+func parseINI(r io.Reader) ([]iniSection, error) {
+	var sections []iniSection
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[':
+			name, ok := strings.CutSuffix(line[1:], "]")
+			if !ok || name == "" {
+				return nil, fmt.Errorf("line %d: invalid section %q", lineNo, line)
+			}
+			sections = append(sections,
+				iniSection{Name: strings.TrimSpace(name), Line: lineNo})
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("line %d: expected KEY = VALUE, got %q", lineNo,
+				line)
+		} else if len(sections) == 0 {
+			return nil, fmt.Errorf("line %d: key %q outside of section", lineNo,
+				name)
+		}
+
+		s := &sections[len(sections)-1]
+		s.Keys = append(s.Keys, iniKey{
+			Name:  name,
+			Value: strings.TrimSpace(value),
+			Line:  lineNo,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ini: %w", err)
+	}
+	return sections, nil
+}
@@ -0,0 +1,69 @@
+package wg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseINI(t *testing.T) {
+	sections, err := parseINI(strings.NewReader(`
+# comment
+[Interface]
+PrivateKey = abc=
+  ; indented comment
+[ Peer ]
+AllowedIPs=10.0.0.2/32
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []iniSection{
+		{
+			Name: "Interface",
+			Line: 3,
+			Keys: []iniKey{{Name: "PrivateKey", Value: "abc=", Line: 4}},
+		},
+		{
+			Name: "Peer",
+			Line: 6,
+			Keys: []iniKey{{Name: "AllowedIPs", Value: "10.0.0.2/32", Line: 7}},
+		},
+	}, sections)
+}
+
+func TestParseINI_errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "invalid section",
+			input: "[Interface\n",
+			err:   "line 1: invalid section",
+		},
+		{
+			name:  "empty section",
+			input: "\n[]\n",
+			err:   "line 2: invalid section",
+		},
+		{
+			name:  "without value",
+			input: "[Interface]\nPrivateKey\n",
+			err:   "line 2: expected KEY = VALUE",
+		},
+		{
+			name:  "outside of section",
+			input: "ListenPort = 51820\n",
+			err:   "line 1: key \"ListenPort\" outside of section",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseINI(strings.NewReader(tt.input))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
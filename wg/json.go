@@ -0,0 +1,84 @@
+package wg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// dumpHidden replaces secret keys in JSON, like wg(8) does it.
+const dumpHidden = "(hidden)"
+
+type dumpJSON struct {
+	PrivateKey string     `json:"private_key,omitempty"`
+	PublicKey  string     `json:"public_key,omitempty"`
+	ListenPort uint16     `json:"listen_port,omitempty"`
+	FwMark     uint32     `json:"fwmark,omitempty"`
+	Peers      []DumpPeer `json:"peers"`
+}
+
+// MarshalJSON implements json.Marshaler. Private key is always replaced by
+// "(hidden)".
+func (self Dump) MarshalJSON() ([]byte, error) {
+	d := dumpJSON{
+		PrivateKey: hideKey(self.PrivateKey),
+		PublicKey:  self.PublicKey,
+		ListenPort: self.ListenPort,
+		FwMark:     self.FwMark,
+		Peers:      self.Peers,
+	}
+	if d.Peers == nil {
+		d.Peers = []DumpPeer{}
+	}
+
+	b, err := json.Marshal(&d)
+	if err != nil {
+		return nil, fmt.Errorf("marshal dump: %w", err)
+	}
+	return b, nil
+}
+
+type peerJSON struct {
+	PublicKey       string         `json:"public_key"`
+	PresharedKey    string         `json:"preshared_key,omitempty"`
+	Endpoint        netip.AddrPort `json:"endpoint,omitzero"`
+	AllowedIPs      []netip.Prefix `json:"allowed_ips"`
+	LatestHandshake time.Time      `json:"latest_handshake,omitzero"`
+	Rx              uint64         `json:"rx"`
+	Tx              uint64         `json:"tx"`
+	Keepalive       uint64         `json:"persistent_keepalive,omitempty"`
+	Interface       string         `json:"interface,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Preshared key is always replaced by
+// "(hidden)" and persistent keepalive is in seconds.
+func (self DumpPeer) MarshalJSON() ([]byte, error) {
+	p := peerJSON{
+		PublicKey:       self.PublicKey,
+		PresharedKey:    hideKey(self.PresharedKey),
+		Endpoint:        self.Endpoint,
+		AllowedIPs:      self.AllowedIPs,
+		LatestHandshake: self.LatestHandshake,
+		Rx:              self.Rx,
+		Tx:              self.Tx,
+		Keepalive:       uint64(self.Keepalive / time.Second),
+		Interface:       self.Interface,
+	}
+	if p.AllowedIPs == nil {
+		p.AllowedIPs = []netip.Prefix{}
+	}
+
+	b, err := json.Marshal(&p)
+	if err != nil {
+		return nil, fmt.Errorf("marshal peer %v: %w", self.Name(), err)
+	}
+	return b, nil
+}
+
+func hideKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	return dumpHidden
+}
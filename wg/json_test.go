@@ -0,0 +1,66 @@
+package wg
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump_MarshalJSON(t *testing.T) {
+	dump := Dump{
+		PrivateKey: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		PublicKey:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+		ListenPort: 51820,
+		FwMark:     0x10,
+		Peers: []DumpPeer{
+			{
+				PublicKey:       "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+				PresharedKey:    "DDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDDA=",
+				Endpoint:        netip.MustParseAddrPort("[fd00::1]:51820"),
+				AllowedIPs:      []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+				LatestHandshake: time.Unix(1709565849, 0).UTC(),
+				Rx:              1,
+				Tx:              2,
+				Keepalive:       25 * time.Second,
+				Interface:       "wg0",
+			},
+			{PublicKey: "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA="},
+		},
+	}
+
+	b, err := json.Marshal(&dump)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "private_key": "(hidden)",
+  "public_key": "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+  "listen_port": 51820,
+  "fwmark": 16,
+  "peers": [
+    {
+      "public_key": "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+      "preshared_key": "(hidden)",
+      "endpoint": "[fd00::1]:51820",
+      "allowed_ips": ["10.0.0.2/32"],
+      "latest_handshake": "2024-03-04T15:24:09Z",
+      "rx": 1,
+      "tx": 2,
+      "persistent_keepalive": 25,
+      "interface": "wg0"
+    },
+    {
+      "public_key": "EEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEEA=",
+      "allowed_ips": [],
+      "rx": 0,
+      "tx": 0
+    }
+  ]
+}`, string(b))
+
+	b, err = json.Marshal(Dump{})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"peers": []}`, string(b))
+}
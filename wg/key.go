@@ -0,0 +1,98 @@
+package wg
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// KeyLen is length of wireguard keys in bytes.
+const KeyLen = 32
+
+// keyEncoding rejects non-zero padding bits, like wg(8) does.
+var keyEncoding = base64.StdEncoding.Strict()
+
+// Key is a public, private or preshared key of wireguard.
+type Key [KeyLen]byte
+
+// ParseKey parses base64 encoded key, like wg(8) outputs it.
+func ParseKey(s string) (Key, error) {
+	var k Key
+	if n := keyEncoding.EncodedLen(KeyLen); len(s) != n {
+		return k, fmt.Errorf("invalid key: expected %d base64 chars, got %d", n,
+			len(s))
+	}
+
+	b, err := keyEncoding.DecodeString(s)
+	if err != nil {
+		return k, fmt.Errorf("invalid key of %d chars: %w", len(s), err)
+	} else if len(b) != KeyLen {
+		return k, fmt.Errorf("invalid key: expected %d bytes, got %d", KeyLen,
+			len(b))
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// ParseHexKey parses hex encoded key, like UAPI outputs it.
+func ParseHexKey(s string) (Key, error) {
+	var k Key
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return k, fmt.Errorf("failed decode hex key: %w", err)
+	} else if len(b) != KeyLen {
+		return k, fmt.Errorf("unexpected key length %v", len(b))
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// String returns base64 encoded key.
+func (self Key) String() string {
+	return keyEncoding.EncodeToString(self[:])
+}
+
+// Hex returns hex encoded key.
+func (self Key) Hex() string {
+	return hex.EncodeToString(self[:])
+}
+
+// Fingerprint returns first 8 chars of base64 encoded key, which is enough for
+// displaying it to humans.
+func (self Key) Fingerprint() string {
+	return self.String()[:8]
+}
+
+// KeyFingerprint returns fingerprint of base64 encoded key or s itself, if it's
+// not a valid key.
+func KeyFingerprint(s string) string {
+	k, err := ParseKey(s)
+	if err != nil {
+		return s
+	}
+	return k.Fingerprint()
+}
+
+func (self Key) IsZero() bool {
+	return self == Key{}
+}
+
+// PublicKey returns public key, derived from private key using Curve25519, like
+// wg pubkey does.
+func (self Key) PublicKey() (Key, error) {
+	privateKey, err := ecdh.X25519().NewPrivateKey(self[:])
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	var k Key
+	copy(k[:], privateKey.PublicKey().Bytes())
+	return k, nil
+}
+
+// validKey returns error if s is not a base64 encoded key.
+func validKey(s string) error {
+	_, err := ParseKey(s)
+	return err
+}
@@ -0,0 +1,81 @@
+package wg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKey(t *testing.T) {
+	const s = "9HeG4vaXIRq7lWtnXMKRJSNV/NhwyZCOEkZ9o3k06Do="
+	k, err := ParseKey(s)
+	require.NoError(t, err)
+	assert.Equal(t, s, k.String())
+	assert.Equal(t,
+		"f47786e2f697211abb956b675cc291252355fcd870c9908e12467da37934e83a",
+		k.Hex())
+	assert.Equal(t, "9HeG4vaX", k.Fingerprint())
+	assert.Equal(t, "9HeG4vaX", KeyFingerprint(s))
+	assert.Equal(t, "foobar", KeyFingerprint("foobar"))
+	assert.False(t, k.IsZero())
+
+	k2, err := ParseHexKey(k.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, k, k2)
+
+	publicKey, err := k.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, "E3zbJ+eE37IcGQWorbKgu56e/ZZSzwUz5scVmjRa3UM=",
+		publicKey.String())
+}
+
+func TestParseKey_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{
+			name: "truncated",
+			key:  "9HeG4vaXIRq7lWtnXMKRJSNV/NhwyZCOEkZ9o3k06D",
+			err:  "expected 44 base64 chars, got 42",
+		},
+		{
+			name: "too long",
+			key:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=",
+			err:  "expected 44 base64 chars, got 45",
+		},
+		{
+			name: "33 bytes",
+			key:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB",
+			err:  "expected 32 bytes, got 33",
+		},
+		{
+			name: "padding bits",
+			key:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=",
+			err:  "illegal base64 data",
+		},
+		{
+			name: "not base64",
+			key:  "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB!=",
+			err:  "illegal base64 data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKey(tt.key)
+			require.ErrorContains(t, err, tt.err)
+			assert.NotContains(t, err.Error(), tt.key, "key must not be output")
+		})
+	}
+}
+
+func TestParseHexKey_errors(t *testing.T) {
+	_, err := ParseHexKey("foobar")
+	require.ErrorContains(t, err, "failed decode hex key")
+
+	_, err = ParseHexKey("0000")
+	require.ErrorContains(t, err, "unexpected key length 2")
+}
@@ -0,0 +1,147 @@
+package wg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// netdevIgnoredKeys are keys of [WireGuard] and [WireGuardPeer] sections, used
+// by systemd-networkd(8) itself for routing and not by wireguard.
+var netdevIgnoredKeys = map[string]struct{}{
+	"RouteTable":  {},
+	"RouteMetric": {},
+}
+
+// NewNetdevConfig parses [WireGuard] and [WireGuardPeer] sections of
+// systemd.netdev(5) file. Other sections are ignored, except [NetDev], which
+// must be of wireguard kind. Keys, given by PrivateKeyFile= and
+// PresharedKeyFile=, are read from their files.
+func NewNetdevConfig(r io.Reader) (*Config, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	self := &Config{}
+	var hasWireGuard bool
+	for i := range sections {
+		s := &sections[i]
+		switch s.Name {
+		case "NetDev":
+			if err := checkNetdevKind(s); err != nil {
+				return nil, err
+			}
+		case "WireGuard":
+			// systemd merges repeated sections.
+			if err := self.parseNetdevWireGuard(s); err != nil {
+				return nil, err
+			}
+			hasWireGuard = true
+		case "WireGuardPeer":
+			p, err := parseNetdevPeer(s)
+			if err != nil {
+				return nil, err
+			} else if err := self.addPeer(p, s.Line); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !hasWireGuard {
+		return nil, errors.New("no [WireGuard] section")
+	}
+	return self, nil
+}
+
+func checkNetdevKind(s *iniSection) error {
+	for _, k := range s.Keys {
+		if k.Name == "Kind" && k.Value != "wireguard" {
+			return fmt.Errorf("line %d: expected Kind=wireguard, got %q", k.Line,
+				k.Value)
+		}
+	}
+	return nil
+}
+
+func (self *Config) parseNetdevWireGuard(s *iniSection) error {
+	for _, k := range s.Keys {
+		var err error
+		switch k.Name {
+		case "PrivateKey":
+			self.PrivateKey = k.Value
+		case "PrivateKeyFile":
+			self.PrivateKey, err = readKeyFile(k.Value)
+		case "ListenPort":
+			if k.Value == "auto" || k.Value == "" {
+				self.ListenPort = 0
+			} else {
+				self.ListenPort, err = parseConfigPort(k.Value)
+			}
+		case "FirewallMark":
+			self.FwMark, err = parseConfigFwMark(k.Value)
+		default:
+			if _, ok := netdevIgnoredKeys[k.Name]; !ok {
+				err = fmt.Errorf("unknown key %q", k.Name)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", k.Line, err)
+		}
+	}
+	return nil
+}
+
+func parseNetdevPeer(s *iniSection) (ConfigPeer, error) {
+	var p ConfigPeer
+	for _, k := range s.Keys {
+		var err error
+		switch k.Name {
+		case "PublicKey":
+			p.PublicKey = k.Value
+		case "PresharedKey":
+			p.PresharedKey = k.Value
+		case "PresharedKeyFile":
+			p.PresharedKey, err = readKeyFile(k.Value)
+		case "AllowedIPs":
+			if k.Value == "" {
+				// An empty assignment resets the list.
+				p.AllowedIPs = nil
+				continue
+			}
+			var prefixes []netip.Prefix
+			prefixes, err = parseConfigAllowedIPs(k.Value)
+			p.AllowedIPs = append(p.AllowedIPs, prefixes...)
+		case "Endpoint":
+			p.Endpoint = k.Value
+		case "PersistentKeepalive":
+			p.Keepalive, err = parseConfigKeepalive(k.Value)
+		default:
+			if _, ok := netdevIgnoredKeys[k.Name]; !ok {
+				err = fmt.Errorf("unknown key %q", k.Name)
+			}
+		}
+		if err != nil {
+			return p, fmt.Errorf("line %d: %w", k.Line, err)
+		}
+	}
+	return p, nil
+}
+
+// readKeyFile returns base64 encoded key from given file, like
+// PrivateKeyFile=.
+func readKeyFile(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("read key: %w", err)
+	}
+
+	key := strings.TrimSpace(string(b))
+	if key == "" {
+		return "", fmt.Errorf("empty key file %q", name)
+	}
+	return key, nil
+}
@@ -0,0 +1,128 @@
+package wg
+
+import (
+	"bytes"
+	_ "embed"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/wg0.netdev
+var netdevConfig []byte
+
+func TestNewNetdevConfig(t *testing.T) {
+	cfg, err := NewNetdevConfig(bytes.NewReader(netdevConfig))
+	require.NoError(t, err)
+
+	quick, err := NewQuickConfig(bytes.NewReader(quickConfig))
+	require.NoError(t, err)
+	assert.Equal(t, quick, cfg)
+
+	dump, err := NewDump(bytes.NewReader(showDumpOutput))
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Diff(&dump))
+}
+
+func TestNewNetdevConfig_keys(t *testing.T) {
+	dir := t.TempDir()
+	privateKeyFile := filepath.Join(dir, "wg0.key")
+	require.NoError(t, os.WriteFile(privateKeyFile,
+		[]byte("ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZA=\n"), 0o600))
+	pskFile := filepath.Join(dir, "wg0.psk")
+	require.NoError(t, os.WriteFile(pskFile,
+		[]byte("CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA="), 0o600))
+
+	cfg, err := NewNetdevConfig(strings.NewReader(`
+[WireGuard]
+PrivateKeyFile=` + privateKeyFile + `
+ListenPort=auto
+FirewallMark=0x10
+
+[WireGuardPeer]
+PublicKey=BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=
+PresharedKeyFile=` + pskFile + `
+AllowedIPs=172.16.0.0/12
+AllowedIPs=
+AllowedIPs=10.0.0.2/32 fd00::2/128,192.168.0.0/16
+PersistentKeepalive=off
+`))
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		PrivateKey: "ZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZZA=",
+		FwMark:     0x10,
+		Peers: []ConfigPeer{{
+			PublicKey:    "BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBA=",
+			PresharedKey: "CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCA=",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("fd00::2/128"),
+				netip.MustParsePrefix("192.168.0.0/16"),
+			},
+		}},
+	}, cfg)
+}
+
+func TestNewNetdevConfig_errors(t *testing.T) {
+	emptyKey := filepath.Join(t.TempDir(), "empty.key")
+	require.NoError(t, os.WriteFile(emptyKey, []byte("\n"), 0o600))
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "without wireguard",
+			input: "[NetDev]\nName=wg0\nKind=wireguard\n",
+			err:   "no [WireGuard] section",
+		},
+		{
+			name:  "not wireguard",
+			input: "[NetDev]\nName=br0\nKind=bridge\n",
+			err:   "line 3: expected Kind=wireguard, got \"bridge\"",
+		},
+		{
+			name:  "unknown key",
+			input: "[WireGuard]\nFwMark=1\n",
+			err:   "line 2: unknown key \"FwMark\"",
+		},
+		{
+			name:  "private key file not exists",
+			input: "[WireGuard]\nPrivateKeyFile=" + emptyKey + ".foo\n",
+			err:   "line 2: read key",
+		},
+		{
+			name:  "empty private key file",
+			input: "[WireGuard]\nPrivateKeyFile=" + emptyKey + "\n",
+			err:   "line 2: empty key file",
+		},
+		{
+			name:  "invalid listen port",
+			input: "[WireGuard]\nListenPort=foo\n",
+			err:   "line 2: failed parse port number",
+		},
+		{
+			name:  "peer without public key",
+			input: "[WireGuard]\n[WireGuardPeer]\nEndpoint=10.0.0.1:51820\n",
+			err:   "line 2: peer without public key",
+		},
+		{
+			name:  "invalid allowed ips",
+			input: "[WireGuard]\n[WireGuardPeer]\nAllowedIPs=10.0.0.2/33\n",
+			err:   "line 3: failed parse allowed ip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNetdevConfig(strings.NewReader(tt.input))
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package wg
+
+import (
+	"net/netip"
+)
+
+type OverlapKind int
+
+const (
+	// OverlapIdentical means both peers claim the same prefix.
+	OverlapIdentical OverlapKind = iota + 1
+	// OverlapShadowed means more specific prefix of one peer shadows part of
+	// wider prefix of another peer.
+	OverlapShadowed
+)
+
+func (self OverlapKind) String() string {
+	switch self {
+	case OverlapIdentical:
+		return "identical"
+	case OverlapShadowed:
+		return "shadowed"
+	}
+	return "unknown"
+}
+
+// Overlap describes two peers with overlapping allowed IPs. For
+// OverlapShadowed Prefix is the wider prefix of Peer and OtherPrefix is more
+// specific prefix of Other, which shadows part of it.
+type Overlap struct {
+	Kind        OverlapKind
+	Peer        *DumpPeer
+	Prefix      netip.Prefix
+	Other       *DumpPeer
+	OtherPrefix netip.Prefix
+}
+
+type peerPrefix struct {
+	Peer   *DumpPeer
+	Prefix netip.Prefix
+}
+
+// Overlaps returns all overlapping allowed IPs of given peers. Peers of
+// different interfaces are compared only if acrossInterfaces is true.
+func Overlaps(peers []DumpPeer, acrossInterfaces bool) []Overlap {
+	var prefixes []peerPrefix
+	for i := range peers {
+		p := &peers[i]
+		for _, prefix := range p.AllowedIPs {
+			prefixes = append(prefixes, peerPrefix{Peer: p, Prefix: prefix.Masked()})
+		}
+	}
+
+	var overlaps []Overlap
+	for i, a := range prefixes {
+		for _, b := range prefixes[i+1:] {
+			if a.Peer == b.Peer || !a.Prefix.Overlaps(b.Prefix) {
+				continue
+			} else if !acrossInterfaces && a.Peer.Interface != b.Peer.Interface {
+				continue
+			}
+			overlaps = append(overlaps, newOverlap(a, b))
+		}
+	}
+	return overlaps
+}
+
+func newOverlap(a, b peerPrefix) Overlap {
+	switch {
+	case a.Prefix == b.Prefix:
+		return Overlap{
+			Kind:        OverlapIdentical,
+			Peer:        a.Peer,
+			Prefix:      a.Prefix,
+			Other:       b.Peer,
+			OtherPrefix: b.Prefix,
+		}
+	case a.Prefix.Bits() > b.Prefix.Bits():
+		a, b = b, a
+	}
+	return Overlap{
+		Kind:        OverlapShadowed,
+		Peer:        a.Peer,
+		Prefix:      a.Prefix,
+		Other:       b.Peer,
+		OtherPrefix: b.Prefix,
+	}
+}
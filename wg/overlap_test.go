@@ -0,0 +1,80 @@
+package wg
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlaps(t *testing.T) {
+	peers := []DumpPeer{
+		{
+			PublicKey: "A",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("192.168.0.0/16"),
+			},
+			Interface: "wg0",
+		},
+		{
+			PublicKey: "B",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.3/32"),
+				netip.MustParsePrefix("192.168.1.1/24"),
+			},
+			Interface: "wg0",
+		},
+		{
+			PublicKey:  "C",
+			AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")},
+			Interface:  "wg1",
+		},
+		{
+			PublicKey:  "D",
+			AllowedIPs: []netip.Prefix{netip.MustParsePrefix("fd00::/64")},
+			Interface:  "wg1",
+		},
+	}
+
+	overlaps := Overlaps(peers, false)
+	require.Len(t, overlaps, 1)
+	assert.Equal(t, Overlap{
+		Kind:        OverlapShadowed,
+		Peer:        &peers[0],
+		Prefix:      netip.MustParsePrefix("192.168.0.0/16"),
+		Other:       &peers[1],
+		OtherPrefix: netip.MustParsePrefix("192.168.1.0/24"),
+	}, overlaps[0])
+
+	overlaps = Overlaps(peers, true)
+	require.Len(t, overlaps, 2)
+	assert.Equal(t, Overlap{
+		Kind:        OverlapIdentical,
+		Peer:        &peers[0],
+		Prefix:      netip.MustParsePrefix("10.0.0.2/32"),
+		Other:       &peers[2],
+		OtherPrefix: netip.MustParsePrefix("10.0.0.2/32"),
+	}, overlaps[0])
+	assert.Equal(t, OverlapShadowed, overlaps[1].Kind)
+
+	assert.Empty(t, Overlaps(peers[2:], true))
+}
+
+func TestOverlaps_shadowedOrder(t *testing.T) {
+	peers := []DumpPeer{
+		{AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/32")}},
+		{AllowedIPs: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}},
+	}
+	overlaps := Overlaps(peers, false)
+	require.Len(t, overlaps, 1)
+	assert.Same(t, &peers[1], overlaps[0].Peer)
+	assert.Same(t, &peers[0], overlaps[0].Other)
+}
+
+func TestOverlapKind_String(t *testing.T) {
+	assert.Equal(t, "identical", OverlapIdentical.String())
+	assert.Equal(t, "shadowed", OverlapShadowed.String())
+	assert.Equal(t, "unknown", OverlapKind(0).String())
+}
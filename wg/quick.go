@@ -0,0 +1,121 @@
+package wg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+)
+
+// quickIgnoredKeys are keys of [Interface] section, used by wg-quick(8) itself
+// and not by wireguard.
+var quickIgnoredKeys = map[string]struct{}{
+	"address":    {},
+	"dns":        {},
+	"mtu":        {},
+	"table":      {},
+	"preup":      {},
+	"postup":     {},
+	"predown":    {},
+	"postdown":   {},
+	"saveconfig": {},
+}
+
+// NewQuickConfig parses configuration file of wg-quick(8), like
+// /etc/wireguard/wg0.conf.
+func NewQuickConfig(r io.Reader) (*Config, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	self := &Config{}
+	var hasInterface bool
+	for i := range sections {
+		s := &sections[i]
+		switch strings.ToLower(s.Name) {
+		case "interface":
+			if hasInterface {
+				return nil, fmt.Errorf("line %d: duplicate [Interface] section",
+					s.Line)
+			} else if err := self.parseQuickInterface(s); err != nil {
+				return nil, err
+			}
+			hasInterface = true
+		case "peer":
+			p, err := parseQuickPeer(s)
+			if err != nil {
+				return nil, err
+			} else if err := self.addPeer(p, s.Line); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown section [%s]", s.Line, s.Name)
+		}
+	}
+
+	if !hasInterface {
+		return nil, errors.New("no [Interface] section")
+	}
+	self.AutoFwMark = self.AutoFwMark && self.defaultRoute()
+	return self, nil
+}
+
+// parseQuickInterface parses [Interface] section. wg-quick(8) sets fwmark
+// itself, if it isn't configured and Table is auto, which is the default, so
+// AutoFwMark is set here and NewQuickConfig checks default route of peers.
+func (self *Config) parseQuickInterface(s *iniSection) error {
+	self.AutoFwMark = true
+	for _, k := range s.Keys {
+		var err error
+		switch name := strings.ToLower(k.Name); name {
+		case "privatekey":
+			self.PrivateKey = k.Value
+		case "listenport":
+			self.ListenPort, err = parseConfigPort(k.Value)
+		case "fwmark":
+			self.FwMark, err = parseConfigFwMark(k.Value)
+			self.AutoFwMark = false
+		case "table":
+			if k.Value != "auto" {
+				self.AutoFwMark = false
+			}
+		default:
+			if _, ok := quickIgnoredKeys[name]; !ok {
+				err = fmt.Errorf("unknown key %q", k.Name)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", k.Line, err)
+		}
+	}
+	return nil
+}
+
+func parseQuickPeer(s *iniSection) (ConfigPeer, error) {
+	var p ConfigPeer
+	for _, k := range s.Keys {
+		var err error
+		switch strings.ToLower(k.Name) {
+		case "publickey":
+			p.PublicKey = k.Value
+		case "presharedkey":
+			p.PresharedKey = k.Value
+		case "allowedips":
+			var prefixes []netip.Prefix
+			prefixes, err = parseConfigAllowedIPs(k.Value)
+			p.AllowedIPs = append(p.AllowedIPs, prefixes...)
+		case "endpoint":
+			p.Endpoint = k.Value
+		case "persistentkeepalive":
+			p.Keepalive, err = parseConfigKeepalive(k.Value)
+		default:
+			err = fmt.Errorf("unknown key %q", k.Name)
+		}
+		if err != nil {
+			return p, fmt.Errorf("line %d: %w", k.Line, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,74 @@
+package wg
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// NewRoutes creates longest-prefix-match index over allowed IPs of given
+// peers. If some prefix is claimed by multiple peers, the last one wins, like
+// wireguard moves allowed IP to the last peer, which claimed it.
+func NewRoutes(peers []DumpPeer) *Routes {
+	self := &Routes{prefixes: make(map[netip.Prefix]*DumpPeer)}
+	for i := range peers {
+		p := &peers[i]
+		for _, prefix := range p.AllowedIPs {
+			self.add(prefix, p)
+		}
+	}
+
+	for _, bits := range [...]*[]int{&self.bits4, &self.bits6} {
+		slices.Sort(*bits)
+		slices.Reverse(*bits)
+		*bits = slices.Compact(*bits)
+	}
+	return self
+}
+
+// Routes finds peer, which routes given address, like wireguard does it
+// using allowed IPs.
+type Routes struct {
+	prefixes     map[netip.Prefix]*DumpPeer
+	bits4, bits6 []int
+}
+
+func (self *Routes) add(prefix netip.Prefix, peer *DumpPeer) {
+	prefix = prefix.Masked()
+	_, ok := self.prefixes[prefix]
+	self.prefixes[prefix] = peer
+	if ok {
+		return
+	}
+
+	if prefix.Addr().Is4() {
+		self.bits4 = append(self.bits4, prefix.Bits())
+	} else {
+		self.bits6 = append(self.bits6, prefix.Bits())
+	}
+}
+
+// Lookup returns peer, which routes given address, and its allowed IP with the
+// longest prefix, which matched the address. It returns nil if no peer found.
+func (self *Routes) Lookup(addr netip.Addr) (*DumpPeer, netip.Prefix) {
+	addr = addr.Unmap().WithZone("")
+	bits := self.bits6
+	if addr.Is4() {
+		bits = self.bits4
+	}
+
+	for _, n := range bits {
+		prefix, err := addr.Prefix(n)
+		if err != nil {
+			continue
+		} else if peer, ok := self.prefixes[prefix]; ok {
+			return peer, prefix
+		}
+	}
+	return nil, netip.Prefix{}
+}
+
+// Route returns peer, which routes given address, and matched allowed IP. See
+// [Routes.Lookup].
+func (self *Dump) Route(addr netip.Addr) (*DumpPeer, netip.Prefix) {
+	return NewRoutes(self.Peers).Lookup(addr)
+}
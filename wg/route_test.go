@@ -0,0 +1,79 @@
+package wg
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutes_Lookup(t *testing.T) {
+	peers := []DumpPeer{
+		{
+			PublicKey: "A",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("192.168.0.0/16"),
+			},
+		},
+		{
+			PublicKey: "B",
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.3/32"),
+				netip.MustParsePrefix("192.168.1.1/24"),
+				netip.MustParsePrefix("fd00::/64"),
+			},
+		},
+		{
+			PublicKey:  "C",
+			AllowedIPs: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+		},
+		{
+			PublicKey:  "D",
+			AllowedIPs: []netip.Prefix{netip.MustParsePrefix("10.0.0.3/32")},
+		},
+		{PublicKey: "E"},
+	}
+	routes := NewRoutes(peers)
+
+	tests := []struct {
+		addr   string
+		peer   string
+		prefix string
+	}{
+		{addr: "10.0.0.2", peer: "A", prefix: "10.0.0.2/32"},
+		// the last peer claimed 10.0.0.3/32 wins, like in wireguard.
+		{addr: "10.0.0.3", peer: "D", prefix: "10.0.0.3/32"},
+		{addr: "192.168.2.1", peer: "A", prefix: "192.168.0.0/16"},
+		{addr: "192.168.1.100", peer: "B", prefix: "192.168.1.0/24"},
+		{addr: "::ffff:192.168.1.100", peer: "B", prefix: "192.168.1.0/24"},
+		{addr: "fd00::1", peer: "B", prefix: "fd00::/64"},
+		{addr: "8.8.8.8", peer: "C", prefix: "0.0.0.0/0"},
+		{addr: "fd01::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			peer, prefix := routes.Lookup(netip.MustParseAddr(tt.addr))
+			if tt.peer == "" {
+				assert.Nil(t, peer)
+				assert.False(t, prefix.IsValid())
+				return
+			}
+			require.NotNil(t, peer)
+			assert.Equal(t, tt.peer, peer.PublicKey)
+			assert.Equal(t, netip.MustParsePrefix(tt.prefix), prefix)
+		})
+	}
+}
+
+func TestDump_Route(t *testing.T) {
+	dump := testDump
+	peer, prefix := dump.Route(netip.MustParseAddr("10.0.0.4"))
+	assert.Same(t, &dump.Peers[2], peer)
+	assert.Equal(t, dump.Peers[2].AllowedIPs[0], prefix)
+
+	peer, _ = dump.Route(netip.MustParseAddr("10.0.0.1"))
+	assert.Nil(t, peer)
+}
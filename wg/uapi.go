@@ -0,0 +1,189 @@
+package wg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UAPIDir is directory with UAPI sockets of userspace wireguard
+// implementations, like wireguard-go or boringtun.
+const UAPIDir = "/var/run/wireguard"
+
+// UAPISocket returns interface name and path of UAPI socket for given
+// interface name or socket path.
+func UAPISocket(s string) (name, path string) {
+	if strings.ContainsRune(s, '/') {
+		name, _ = strings.CutSuffix(filepath.Base(s), ".sock")
+		return name, s
+	}
+	return s, filepath.Join(UAPIDir, s+".sock")
+}
+
+// ReadUAPI connects to UAPI socket with given path, requests configuration of
+// wireguard interface and returns it as Dump.
+func ReadUAPI(ctx context.Context, path string) (Dump, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return Dump{}, fmt.Errorf("dial %q: %w", path, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return Dump{}, fmt.Errorf("set deadline of %q: %w", path, err)
+		}
+	}
+
+	if _, err := io.WriteString(conn, "get=1\n\n"); err != nil {
+		return Dump{}, fmt.Errorf("write get request to %q: %w", path, err)
+	}
+	return NewUAPIDump(conn)
+}
+
+func NewUAPIDump(r io.Reader) (Dump, error) {
+	var dump Dump
+	return dump, dump.ParseUAPI(r)
+}
+
+// ParseUAPI parses response of UAPI get operation. See
+// https://www.wireguard.com/xplatform/#configuration-protocol
+func (self *Dump) ParseUAPI(r io.Reader) error {
+	var peer *DumpPeer
+	var handshake uapiHandshake
+	finishPeer := func() {
+		if peer != nil {
+			peer.finishUAPI(&handshake)
+			self.Peers = append(self.Peers, *peer)
+			peer, handshake = nil, uapiHandshake{}
+		}
+	}
+
+	lines := bufio.NewScanner(r)
+	for lines.Scan() {
+		line := lines.Text()
+		if line == "" {
+			finishPeer()
+			return nil
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return fmt.Errorf("parse uapi line %q: missing '='", line)
+		}
+
+		var err error
+		switch {
+		case key == "errno":
+			err = parseUAPIErrno(value)
+		case key == "public_key":
+			finishPeer()
+			peer = new(DumpPeer)
+			peer.PublicKey, err = parseUAPIKey(value)
+		case peer == nil:
+			err = self.parseUAPIKeyValue(key, value)
+		default:
+			err = peer.parseUAPIKeyValue(key, value, &handshake)
+		}
+		if err != nil {
+			return fmt.Errorf("parse uapi line %q: %w", line, err)
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return fmt.Errorf("read uapi response: %w", err)
+	}
+	return fmt.Errorf("read uapi response: %w", io.ErrUnexpectedEOF)
+}
+
+func parseUAPIErrno(s string) error {
+	errno, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("failed parse errno %q: %w", s, err)
+	} else if errno != 0 {
+		return fmt.Errorf("uapi returned errno=%v", errno)
+	}
+	return nil
+}
+
+func parseUAPIKey(s string) (string, error) {
+	k, err := ParseHexKey(s)
+	if err != nil {
+		return "", err
+	} else if k.IsZero() {
+		return "", nil
+	}
+	return k.String(), nil
+}
+
+func (self *Dump) parseUAPIKeyValue(key, value string) (err error) {
+	switch key {
+	case "private_key":
+		err = self.parseUAPIPrivateKey(value)
+	case "listen_port":
+		err = self.parseListenPort(value)
+	case "fwmark":
+		err = self.parseFwMark(value)
+	}
+	return
+}
+
+// parseUAPIPrivateKey sets private key and public key derived from it, because
+// UAPI doesn't output public key of interface.
+func (self *Dump) parseUAPIPrivateKey(s string) error {
+	k, err := ParseHexKey(s)
+	if err != nil {
+		return err
+	} else if k.IsZero() {
+		return nil
+	}
+
+	publicKey, err := k.PublicKey()
+	if err != nil {
+		return err
+	}
+	self.PrivateKey, self.PublicKey = k.String(), publicKey.String()
+	return nil
+}
+
+type uapiHandshake struct {
+	Sec, Nsec int64
+}
+
+func (self *DumpPeer) parseUAPIKeyValue(key, value string,
+	handshake *uapiHandshake,
+) (err error) {
+	switch key {
+	case "preshared_key":
+		self.PresharedKey, err = parseUAPIKey(value)
+	case "endpoint":
+		err = self.parseEndpoint(value)
+	case "allowed_ip":
+		err = self.parseAllowedIP(value)
+	case "last_handshake_time_sec":
+		handshake.Sec, err = strconv.ParseInt(value, 10, 64)
+	case "last_handshake_time_nsec":
+		handshake.Nsec, err = strconv.ParseInt(value, 10, 64)
+	case "rx_bytes":
+		self.Rx, err = strconv.ParseUint(value, 10, 64)
+	case "tx_bytes":
+		self.Tx, err = strconv.ParseUint(value, 10, 64)
+	case "persistent_keepalive_interval":
+		err = self.parseKeepalive(value)
+	}
+	return
+}
+
+func (self *DumpPeer) finishUAPI(handshake *uapiHandshake) {
+	if handshake.Sec != 0 || handshake.Nsec != 0 {
+		self.LatestHandshake = time.Unix(handshake.Sec, handshake.Nsec)
+	}
+	self.valid = true
+}
@@ -0,0 +1,168 @@
+package wg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"io"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:embed testdata/uapi_get.txt
+var uapiGetOutput []byte
+
+func TestUAPISocket(t *testing.T) {
+	name, path := UAPISocket("wg0")
+	assert.Equal(t, "wg0", name)
+	assert.Equal(t, "/var/run/wireguard/wg0.sock", path)
+
+	name, path = UAPISocket("/tmp/wg1.sock")
+	assert.Equal(t, "wg1", name)
+	assert.Equal(t, "/tmp/wg1.sock", path)
+}
+
+func TestDump_ParseUAPI(t *testing.T) {
+	dump, err := NewUAPIDump(bytes.NewBuffer(uapiGetOutput))
+	require.NoError(t, err)
+
+	want := testDump
+	want.PrivateKey = "YAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	want.PublicKey = "Y2FD6uTgrq+/bbBAXCNOtE+PGk8Papoh7qaWBpQUXn0="
+	want.Peers = []DumpPeer{
+		{
+			PublicKey:    "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=",
+			PresharedKey: "",
+			Endpoint:     netip.MustParseAddrPort("10.0.0.1:54321"),
+			AllowedIPs: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.2/32"),
+				netip.MustParsePrefix("192.168.1.0/24"),
+			},
+			LatestHandshake: time.Unix(1709565849, 123),
+			Rx:              293787123,
+			Tx:              2098018008,
+			Keepalive:       15 * time.Second,
+			valid:           true,
+		},
+		{
+			PublicKey:    "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0=",
+			PresharedKey: "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE=",
+			valid:        true,
+		},
+	}
+	assert.Equal(t, want, dump)
+}
+
+func TestDump_ParseUAPI_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			wantErr: "unexpected EOF",
+		},
+		{
+			name:    "without empty line",
+			input:   "errno=0\n",
+			wantErr: "unexpected EOF",
+		},
+		{
+			name:    "missing =",
+			input:   "foobar\n\n",
+			wantErr: "missing '='",
+		},
+		{
+			name:    "errno",
+			input:   "errno=1\n\n",
+			wantErr: "uapi returned errno=1",
+		},
+		{
+			name:    "invalid errno",
+			input:   "errno=X\n\n",
+			wantErr: "failed parse errno",
+		},
+		{
+			name:    "invalid key",
+			input:   "private_key=XX\n\n",
+			wantErr: "failed decode hex key",
+		},
+		{
+			name:    "short key",
+			input:   "private_key=0102\n\n",
+			wantErr: "unexpected key length 2",
+		},
+		{
+			name:    "listen port",
+			input:   "listen_port=X\n\n",
+			wantErr: "failed parse port number",
+		},
+		{
+			name: "rx bytes",
+			input: "public_key=" + strings.Repeat("01", 32) +
+				"\nrx_bytes=X\n\n",
+			wantErr: `parse uapi line "rx_bytes=X"`,
+		},
+		{
+			name: "allowed ip",
+			input: "public_key=" + strings.Repeat("01", 32) +
+				"\nallowed_ip=10.0.0.2\n\n",
+			wantErr: "parse allowed ip",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewUAPIDump(strings.NewReader(tt.input))
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestReadUAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wg0.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	requests := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var request string
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			request += line
+			if err != nil || line == "\n" {
+				break
+			}
+		}
+		requests <- request
+		_, _ = io.Copy(conn, bytes.NewReader(uapiGetOutput))
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	dump, err := ReadUAPI(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, "get=1\n\n", <-requests)
+	assert.Len(t, dump.Peers, 2)
+
+	_, err = ReadUAPI(ctx, filepath.Join(t.TempDir(), "wg1.sock"))
+	require.ErrorContains(t, err, "dial")
+}